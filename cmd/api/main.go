@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"log"
 	"time"
 
@@ -10,6 +11,7 @@ import (
 	"sample-miniapp-backend/internal/config"
 	"sample-miniapp-backend/internal/handlers"
 	"sample-miniapp-backend/internal/middleware"
+	"sample-miniapp-backend/internal/models"
 	"sample-miniapp-backend/internal/services"
 )
 
@@ -29,11 +31,16 @@ func main() {
 	}
 	defer redisService.Close()
 
-	jwtService := services.NewJWTService(cfg)
+	jwtService, err := services.NewJWTService(cfg, redisService)
+	if err != nil {
+		log.Fatalf("Failed to set up JWT signing: %v", err)
+	}
+	go jwtService.RunRotation(context.Background())
 
 	gameEngine := services.NewGameEngine(redisService)
-	wsHandler := handlers.NewWebSocketHandler(gameEngine, redisService)
+	wsHandler := handlers.NewWebSocketHandler(gameEngine, redisService, cfg.WebSocket)
 	gameEngine.SetBroadcaster(wsHandler)
+	redisService.SetWalletNotifier(wsHandler)
 
 	go func() {
 		ticker := time.NewTicker(5 * time.Minute)
@@ -44,20 +51,47 @@ func main() {
 		}
 	}()
 
-	authHandler := handlers.NewAuthHandler(redisService, jwtService, cfg.BotToken)
+	if cfg.ArchiveDir != "" {
+		archiveStore, err := services.NewFileArchiveStore(cfg.ArchiveDir)
+		if err != nil {
+			log.Fatalf("Failed to open game archive: %v", err)
+		}
+		redisService.SetArchiveStore(archiveStore)
+
+		archiver, err := services.NewGameArchiver(redisService, archiveStore, cfg.ArchiveAfter, time.Hour)
+		if err != nil {
+			log.Fatalf("Invalid ARCHIVE_AFTER: %v", err)
+		}
+		go archiver.Run(context.Background())
+	}
+
+	webhookService := services.NewWebhookService(redisService)
+	go webhookService.Run(context.Background())
+
+	roleService := services.NewRoleService(redisService, staticRoles(cfg))
+
+	authHandler := handlers.NewAuthHandler(redisService, jwtService, roleService, cfg.BotToken, cfg.Telegram)
 	userHandler := handlers.NewUserHandler(redisService, gameEngine)
 	gameHandler := handlers.NewGameHandler(gameEngine, redisService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, redisService)
+	feedHandler := handlers.NewFeedHandler(redisService)
+	adminHandler := handlers.NewAdminHandler(gameEngine, redisService, roleService, cfg.CreditApprovalThreshold)
 
 	if cfg.Env == "production" {
 		gin.SetMode(gin.ReleaseMode)
 	}
 
 	router := gin.Default()
+	// Client IPs feed API key allowlists and the audit log, so only take
+	// X-Forwarded-For from proxies we run
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid TRUSTED_PROXIES: %v", err)
+	}
 
 	router.Use(func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-API-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -68,14 +102,25 @@ func main() {
 	})
 
 	router.GET("/auth/telegram", authHandler.Authenticate)
+	router.POST("/auth/telegram/widget", authHandler.AuthenticateWidget)
+	router.POST("/auth/refresh", authHandler.Refresh)
+	router.GET("/.well-known/jwks.json", authHandler.JWKS)
+	router.GET("/api/ws", middleware.WebSocketAuthMiddleware(jwtService, redisService), wsHandler.HandleWebSocket)
+	router.GET("/api/events", middleware.EventStreamAuthMiddleware(jwtService, redisService), wsHandler.HandleEvents)
 
 	protected := router.Group("/api")
-	protected.Use(middleware.AuthMiddleware(jwtService))
+	protected.Use(middleware.AuthMiddleware(jwtService, redisService))
 	{
 		protected.GET("/me", userHandler.GetCurrentUser)
 		protected.POST("/logout", userHandler.Logout)
+		protected.GET("/sessions", userHandler.ListSessions)
+		protected.DELETE("/sessions", userHandler.RevokeAllSessions)
+		protected.DELETE("/sessions/:id", userHandler.RevokeSession)
+		protected.GET("/me/privacy", userHandler.GetPrivacy)
+		protected.PUT("/me/privacy", userHandler.UpdatePrivacy)
 
-		protected.GET("/ws", wsHandler.HandleWebSocket)
+		protected.GET("/feed", feedHandler.GetFeed)
+		protected.POST("/events/ticket", wsHandler.IssueEventsTicket)
 
 		games := protected.Group("/games")
 		{
@@ -101,6 +146,100 @@ func main() {
 		}
 	}
 
+	adminOnly := middleware.RequireRole(models.RoleAdmin)
+	readOnly := middleware.RequireRole(models.RoleAdmin, models.RoleAuditor)
+	supportDesk := middleware.RequireRole(models.RoleAdmin, models.RoleSupport)
+
+	admin := router.Group("/admin")
+	admin.Use(
+		middleware.AuthMiddleware(jwtService, redisService),
+		// Staff roles are looked up on every request, not taken from the token
+		middleware.ResolveRole(roleService),
+		middleware.RequireRole(models.RoleAdmin, models.RoleSupport, models.RoleAuditor),
+	)
+	{
+		admin.GET("/roles", readOnly, adminHandler.ListRoles)
+		admin.GET("/audit", readOnly, adminHandler.GetAuditLog)
+
+		players := admin.Group("/users/:id")
+		{
+			players.GET("", adminHandler.GetPlayer)
+			players.GET("/games", adminHandler.GetPlayerGames)
+			players.GET("/transactions", adminHandler.GetPlayerTransactions)
+			players.GET("/bet-patterns", adminHandler.GetPlayerBetPatterns)
+
+			players.POST("/balance", supportDesk, adminHandler.AdjustBalance)
+			players.POST("/freeze", supportDesk, adminHandler.FreezeAccount)
+			players.POST("/unfreeze", supportDesk, adminHandler.UnfreezeAccount)
+			players.PUT("/status", supportDesk, adminHandler.SetAccountStatus)
+			players.DELETE("/sessions", supportDesk, adminHandler.RevokeSessions)
+			players.DELETE("/rate-limit", supportDesk, adminHandler.ClearRateLimits)
+
+			players.PUT("/role", adminOnly, adminHandler.SetRole)
+			players.DELETE("/wallet", adminOnly, adminHandler.DeleteWallet)
+		}
+
+		adjustments := admin.Group("/balance-adjustments")
+		{
+			adjustments.GET("", readOnly, adminHandler.ListPendingAdjustments)
+			adjustments.POST("/:id/approve", adminOnly, adminHandler.ApproveAdjustment)
+			adjustments.POST("/:id/reject", adminOnly, adminHandler.RejectAdjustment)
+		}
+
+		admin.POST("/games/:id/crash", adminOnly, adminHandler.ForceCrash)
+		admin.POST("/games/:id/settle", adminOnly, adminHandler.ForceSettle)
+		admin.GET("/game-config", readOnly, adminHandler.ListGameConfigs)
+		admin.PATCH("/game-config/:type", adminOnly, adminHandler.UpdateGameConfig)
+		admin.GET("/game-config/:type/history", readOnly, adminHandler.GetGameConfigHistory)
+		admin.POST("/seed/rotate", adminOnly, adminHandler.RotateServerSeed)
+
+		apiKeys := admin.Group("/api-keys")
+		{
+			apiKeys.GET("", readOnly, adminHandler.ListAPIKeys)
+			apiKeys.POST("", adminOnly, adminHandler.CreateAPIKey)
+			apiKeys.DELETE("/:id", adminOnly, adminHandler.RevokeAPIKey)
+		}
+
+		webhooks := admin.Group("/webhooks")
+		{
+			webhooks.GET("", readOnly, webhookHandler.ListSubscriptions)
+			webhooks.POST("", adminOnly, webhookHandler.CreateSubscription)
+			webhooks.DELETE("/:id", adminOnly, webhookHandler.DeleteSubscription)
+			webhooks.GET("/deliveries", readOnly, webhookHandler.ListDeliveries)
+			webhooks.GET("/deliveries/:id", readOnly, webhookHandler.GetDelivery)
+			webhooks.POST("/deliveries/:id/replay", adminOnly, webhookHandler.ReplayDelivery)
+		}
+	}
+
+	// /service is the admin API for backend callers, authenticated by API
+	// key instead of a user token. Each route needs a scope on the key.
+	service := router.Group("/service")
+	service.Use(middleware.APIKeyMiddleware(redisService))
+	{
+		playersRead := middleware.RequireScope(models.ScopePlayersRead)
+		playersWrite := middleware.RequireScope(models.ScopePlayersWrite)
+
+		players := service.Group("/users/:id")
+		{
+			players.GET("", playersRead, adminHandler.GetPlayer)
+			players.GET("/games", playersRead, adminHandler.GetPlayerGames)
+			players.GET("/transactions", playersRead, adminHandler.GetPlayerTransactions)
+			players.GET("/bet-patterns", playersRead, adminHandler.GetPlayerBetPatterns)
+
+			players.POST("/balance", playersWrite, adminHandler.AdjustBalance)
+			players.POST("/freeze", playersWrite, adminHandler.FreezeAccount)
+			players.POST("/unfreeze", playersWrite, adminHandler.UnfreezeAccount)
+			players.PUT("/status", playersWrite, adminHandler.SetAccountStatus)
+			players.DELETE("/sessions", playersWrite, adminHandler.RevokeSessions)
+			players.DELETE("/rate-limit", playersWrite, adminHandler.ClearRateLimits)
+		}
+
+		service.POST("/games/:id/settle", middleware.RequireScope(models.ScopeGamesSettle), adminHandler.ForceSettle)
+		service.GET("/game-config", middleware.RequireScope(models.ScopeGameConfigRead), adminHandler.ListGameConfigs)
+		service.PATCH("/game-config/:type", middleware.RequireScope(models.ScopeGameConfigWrite), adminHandler.UpdateGameConfig)
+		service.GET("/audit", middleware.RequireScope(models.ScopeAuditRead), adminHandler.GetAuditLog)
+	}
+
 	port := cfg.Port
 	if port == "" {
 		port = "8080"
@@ -111,3 +250,19 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// staticRoles maps the staff IDs from configuration to their roles. An ID
+// listed twice gets the most privileged role.
+func staticRoles(cfg *config.Config) map[int64]models.Role {
+	roles := make(map[int64]models.Role)
+	for _, id := range cfg.AuditorIDs {
+		roles[id] = models.RoleAuditor
+	}
+	for _, id := range cfg.SupportIDs {
+		roles[id] = models.RoleSupport
+	}
+	for _, id := range cfg.AdminIDs {
+		roles[id] = models.RoleAdmin
+	}
+	return roles
+}
@@ -1,8 +1,12 @@
 package config
 
 import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 )
 
@@ -11,10 +15,65 @@ type Config struct {
 	Env       string
 	JWTSecret string
 	JWTExpiry time.Duration
-	RedisURL  string
-	RedisPass string
-	RedisDB   int
-	BotToken  string
+	// RefreshExpiry is how long a session survives without a refresh; each
+	// refresh slides it forward.
+	RefreshExpiry time.Duration
+	// JWTAlgorithm is HS256 (shared secret), RS256 or EdDSA. The asymmetric
+	// ones use generated keys rotated every JWTKeyRotation.
+	JWTAlgorithm   string
+	JWTKeyRotation time.Duration
+	// JWTKeyEncryptionKey is the AES-256 key that seals the asymmetric
+	// private keys before they are shared through Redis
+	JWTKeyEncryptionKey []byte
+	// JWTIssuer and JWTAudience go in every token's iss and aud claims and
+	// are required when validating
+	JWTIssuer   string
+	JWTAudience string
+	RedisURL    string
+	RedisPass   string
+	RedisDB     int
+	BotToken    string
+	Telegram    TelegramConfig
+
+	// Telegram IDs given a staff role regardless of what is stored in Redis
+	AdminIDs   []int64
+	SupportIDs []int64
+	AuditorIDs []int64
+
+	// CreditApprovalThreshold is the largest manual credit, in cents, staff
+	// or an API key can apply alone; larger ones wait for a second admin
+	CreditApprovalThreshold float64
+
+	// TrustedProxies are the IPs or CIDRs whose X-Forwarded-For is believed.
+	// Empty trusts none, so the client IP is the connection's address.
+	TrustedProxies []string
+
+	ArchiveDir   string
+	ArchiveAfter time.Duration
+
+	WebSocket WebSocketConfig
+}
+
+type WebSocketConfig struct {
+	PingInterval    time.Duration
+	PongTimeout     time.Duration // idle connections are dropped after this
+	MaxMessageBytes int64
+	MaxConnsPerUser int
+	MaxConns        int
+	AllowedOrigins  []string // empty allows same-origin only, "*" allows any
+}
+
+type TelegramConfig struct {
+	// InitDataMaxAge is how old initData may be when exchanged for a session.
+	// Each initData is accepted once within this window.
+	InitDataMaxAge time.Duration
+	// LoginWidgetMaxAge does the same for Login Widget payloads from the
+	// browser version
+	LoginWidgetMaxAge time.Duration
+	// PublicKey is Telegram's Ed25519 key for the initData signature field.
+	// When set, initData opened through ThirdPartyBotIDs is accepted too.
+	PublicKey        ed25519.PublicKey
+	ThirdPartyBotIDs []int64
 }
 
 func Load() (*Config, error) {
@@ -25,23 +84,154 @@ func Load() (*Config, error) {
 
 	jwtExpiryStr := os.Getenv("JWT_EXPIRY")
 	if jwtExpiryStr == "" {
-		jwtExpiryStr = "24h"
+		jwtExpiryStr = "15m"
 	}
 	jwtExpiry, err := time.ParseDuration(jwtExpiryStr)
 	if err != nil {
-		jwtExpiry = 24 * time.Hour
+		jwtExpiry = 15 * time.Minute
+	}
+
+	jwtAlgorithm := os.Getenv("JWT_ALGORITHM")
+	if jwtAlgorithm == "" {
+		jwtAlgorithm = "HS256"
+	}
+
+	var jwtKeyEncryptionKey []byte
+	if keyHex := os.Getenv("JWT_KEY_ENCRYPTION_KEY"); keyHex != "" {
+		jwtKeyEncryptionKey, err = hex.DecodeString(keyHex)
+		if err != nil || len(jwtKeyEncryptionKey) != 32 {
+			return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY must be 32 hex encoded bytes")
+		}
 	}
 
 	redisDB, _ := strconv.Atoi(os.Getenv("REDIS_DB"))
 
+	// Checked against the game session TTL when the archiver is created
+	archiveAfter := 48 * time.Hour
+	if v := os.Getenv("ARCHIVE_AFTER"); v != "" {
+		archiveAfter, err = time.ParseDuration(v)
+		if err != nil || archiveAfter <= 0 {
+			return nil, fmt.Errorf("ARCHIVE_AFTER must be a positive duration")
+		}
+	}
+
+	wsConfig := WebSocketConfig{
+		PingInterval:    durationEnv("WS_PING_INTERVAL", 25*time.Second),
+		PongTimeout:     durationEnv("WS_PONG_TIMEOUT", 60*time.Second),
+		MaxMessageBytes: int64(intEnv("WS_MAX_MESSAGE_BYTES", 4096)),
+		MaxConnsPerUser: intEnv("WS_MAX_CONNS_PER_USER", 5),
+		MaxConns:        intEnv("WS_MAX_CONNS", 10000),
+		AllowedOrigins:  listEnv("WS_ALLOWED_ORIGINS"),
+	}
+	// A ping must fit inside the pong window or healthy clients time out
+	if wsConfig.PingInterval >= wsConfig.PongTimeout {
+		wsConfig.PingInterval = wsConfig.PongTimeout * 9 / 10
+	}
+
+	telegramConfig := TelegramConfig{
+		InitDataMaxAge:    durationEnv("TELEGRAM_INIT_DATA_MAX_AGE", 24*time.Hour),
+		LoginWidgetMaxAge: durationEnv("TELEGRAM_LOGIN_WIDGET_MAX_AGE", 24*time.Hour),
+	}
+	if keyHex := os.Getenv("TELEGRAM_PUBLIC_KEY"); keyHex != "" {
+		key, err := hex.DecodeString(keyHex)
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("TELEGRAM_PUBLIC_KEY must be a hex encoded Ed25519 public key")
+		}
+		telegramConfig.PublicKey = key
+	}
+	telegramConfig.ThirdPartyBotIDs, err = int64ListEnv("TELEGRAM_THIRD_PARTY_BOT_IDS")
+	if err != nil {
+		return nil, err
+	}
+
+	adminIDs, err := int64ListEnv("ADMIN_TELEGRAM_IDS")
+	if err != nil {
+		return nil, err
+	}
+	supportIDs, err := int64ListEnv("SUPPORT_TELEGRAM_IDS")
+	if err != nil {
+		return nil, err
+	}
+	auditorIDs, err := int64ListEnv("AUDITOR_TELEGRAM_IDS")
+	if err != nil {
+		return nil, err
+	}
+
 	return &Config{
-		Port:      port,
-		Env:       os.Getenv("ENV"),
-		JWTSecret: os.Getenv("JWT_SECRET"),
-		JWTExpiry: jwtExpiry,
+		Port:           port,
+		Env:            os.Getenv("ENV"),
+		JWTSecret:      os.Getenv("JWT_SECRET"),
+		JWTExpiry:      jwtExpiry,
+		RefreshExpiry:  durationEnv("REFRESH_TOKEN_EXPIRY", 30*24*time.Hour),
+		JWTAlgorithm:   jwtAlgorithm,
+		JWTKeyRotation: durationEnv("JWT_KEY_ROTATION", 7*24*time.Hour),
+
+		JWTKeyEncryptionKey: jwtKeyEncryptionKey,
+		JWTIssuer:           stringEnv("JWT_ISSUER", "miniapp-backend"),
+		JWTAudience:         stringEnv("JWT_AUDIENCE", "miniapp-api"),
+
 		RedisURL:  os.Getenv("REDIS_URL"),
 		RedisPass: os.Getenv("REDIS_PASSWORD"),
 		RedisDB:   redisDB,
 		BotToken:  os.Getenv("TELEGRAM_BOT_TOKEN"),
+		Telegram:  telegramConfig,
+
+		AdminIDs:   adminIDs,
+		SupportIDs: supportIDs,
+		AuditorIDs: auditorIDs,
+
+		CreditApprovalThreshold: float64(intEnv("ADMIN_CREDIT_APPROVAL_THRESHOLD", 100000)),
+
+		TrustedProxies: listEnv("TRUSTED_PROXIES"),
+
+		ArchiveDir:   os.Getenv("ARCHIVE_DIR"),
+		ArchiveAfter: archiveAfter,
+
+		WebSocket: wsConfig,
 	}, nil
 }
+
+func stringEnv(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func durationEnv(key string, def time.Duration) time.Duration {
+	d, err := time.ParseDuration(os.Getenv(key))
+	if err != nil || d <= 0 {
+		return def
+	}
+	return d
+}
+
+func intEnv(key string, def int) int {
+	n, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || n <= 0 {
+		return def
+	}
+	return n
+}
+
+func listEnv(key string) []string {
+	var list []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
+
+func int64ListEnv(key string) ([]int64, error) {
+	var list []int64
+	for _, item := range listEnv(key) {
+		n, err := strconv.ParseInt(item, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ID in %s: %s", key, item)
+		}
+		list = append(list, n)
+	}
+	return list, nil
+}
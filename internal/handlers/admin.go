@@ -0,0 +1,201 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+// AdminHandler serves the staff-only /admin API. Which roles may call each
+// route is decided where the routes are registered.
+type AdminHandler struct {
+	gameEngine   *services.GameEngine
+	redisService *services.RedisService
+	roleService  *services.RoleService
+
+	// Credits above this need a second admin's approval
+	creditApprovalThreshold float64
+}
+
+func NewAdminHandler(gameEngine *services.GameEngine, redisService *services.RedisService, roleService *services.RoleService, creditApprovalThreshold float64) *AdminHandler {
+	return &AdminHandler{
+		gameEngine:              gameEngine,
+		redisService:            redisService,
+		roleService:             roleService,
+		creditApprovalThreshold: creditApprovalThreshold,
+	}
+}
+
+func (h *AdminHandler) ListRoles(c *gin.Context) {
+	roles, err := h.roleService.ListRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list roles",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"roles":   roles,
+		"count":   len(roles),
+	})
+}
+
+// SetRole changes a user's role. It shows up in their tokens from the next
+// refresh.
+func (h *AdminHandler) SetRole(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if userID == c.GetInt64("user_id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	if err := h.roleService.SetRole(userID, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to set role",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditSetRole,
+		TargetUserID: userID,
+		Details:      map[string]interface{}{"role": req.Role},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user_id": userID,
+		"role":    req.Role,
+	})
+}
+
+func (h *AdminHandler) ForceCrash(c *gin.Context) {
+	gameID := c.Param("id")
+
+	if err := h.gameEngine.ForceCrash(gameID); err != nil {
+		c.JSON(statusForGameError(err), gin.H{
+			"error":   "Failed to crash game",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditForceCrash,
+		TargetGameID: gameID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"game_id": gameID,
+	})
+}
+
+// RotateServerSeed starts using a new server seed for new games. The old
+// seed is not revealed here, since games still in play were derived from it.
+func (h *AdminHandler) RotateServerSeed(c *gin.Context) {
+	var req models.RotateSeedRequest
+	if err := c.ShouldBindJSON(&req); err != nil && c.Request.ContentLength > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	hash := h.gameEngine.RotateServerSeed(req.ServerSeed)
+
+	h.audit(c, &models.AuditEntry{
+		Action:  models.AuditRotateServerSeed,
+		Details: map[string]interface{}{"server_seed_hash": hash},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"server_seed_hash": hash,
+	})
+}
+
+func (h *AdminHandler) DeleteWallet(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	if err := h.redisService.DeleteWallet(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete wallet",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditDeleteWallet,
+		TargetUserID: userID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user_id": userID,
+	})
+}
+
+// ClearRateLimits resets every rate limit counter for the player.
+func (h *AdminHandler) ClearRateLimits(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.redisService.ClearUserRateLimits(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to clear rate limits",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditClearRateLimits,
+		TargetUserID: userID,
+		Details:      map[string]interface{}{"cleared": count},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user_id": userID,
+		"cleared": count,
+	})
+}
+
+func userIDParam(c *gin.Context) (int64, bool) {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return 0, false
+	}
+	return userID, true
+}
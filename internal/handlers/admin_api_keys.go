@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func (h *AdminHandler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.redisService.ListAPIKeys()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list API keys",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"api_keys": keys,
+		"count":    len(keys),
+	})
+}
+
+// CreateAPIKey issues a key for a backend caller. The secret is in this
+// response only; we keep just its hash.
+func (h *AdminHandler) CreateAPIKey(c *gin.Context) {
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	key, secret, err := h.redisService.CreateAPIKey(&req, c.GetInt64("user_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to create API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action: models.AuditCreateAPIKey,
+		Details: map[string]interface{}{
+			"api_key_id":  key.ID,
+			"name":        key.Name,
+			"scopes":      key.Scopes,
+			"allowed_ips": key.AllowedIPs,
+			"rate_limit":  key.RateLimit,
+		},
+	})
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"api_key": key,
+		"secret":  secret,
+	})
+}
+
+func (h *AdminHandler) RevokeAPIKey(c *gin.Context) {
+	key, err := h.redisService.RevokeAPIKey(c.Param("id"))
+	if err == services.ErrAPIKeyNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke API key",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:  models.AuditRevokeAPIKey,
+		Details: map[string]interface{}{"api_key_id": key.ID, "name": key.Name},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"api_key": key,
+	})
+}
@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+// ForceSettle ends a stuck game, either as a crash or by refunding the
+// stake.
+func (h *AdminHandler) ForceSettle(c *gin.Context) {
+	gameID := c.Param("id")
+
+	var req models.ForceSettleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.gameEngine.ForceSettle(gameID, req.Outcome); err != nil {
+		c.JSON(statusForGameError(err), gin.H{
+			"error":   "Failed to settle game",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	entry := &models.AuditEntry{
+		Action:       models.AuditForceSettle,
+		TargetGameID: gameID,
+		Reason:       req.Reason,
+		Details:      map[string]interface{}{"outcome": req.Outcome},
+	}
+	if session, err := h.redisService.GetGameSession(gameID); err == nil {
+		entry.TargetUserID = session.UserID
+	}
+	h.audit(c, entry)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"game_id": gameID,
+		"outcome": req.Outcome,
+	})
+}
+
+func (h *AdminHandler) ListGameConfigs(c *gin.Context) {
+	configs, err := h.redisService.ListGameConfigs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get game configs",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"configs": configs,
+	})
+}
+
+// UpdateGameConfig changes a game's live configuration. Setting enabled to
+// false is the kill switch for new bets; games in play run to completion.
+func (h *AdminHandler) UpdateGameConfig(c *gin.Context) {
+	gameType, ok := gameTypeParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.GameConfigUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	cfg, err := h.redisService.UpdateGameConfig(gameType, &req, c.GetInt64("user_id"))
+	if errors.Is(err, services.ErrGameConfigConflict) {
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to update game config",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:  models.AuditUpdateGameConfig,
+		Reason:  req.Reason,
+		Details: map[string]interface{}{"game_type": gameType, "config": cfg},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"config":  cfg,
+	})
+}
+
+func (h *AdminHandler) GetGameConfigHistory(c *gin.Context) {
+	gameType, ok := gameTypeParam(c)
+	if !ok {
+		return
+	}
+
+	history, err := h.redisService.GameConfigHistory(gameType, limitQuery(c, 50, int64(services.GameConfigHistoryLen)))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get game config history",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"history": history,
+		"count":   len(history),
+	})
+}
+
+func gameTypeParam(c *gin.Context) (models.GameType, bool) {
+	gameType := models.GameType(c.Param("type"))
+	for _, known := range models.ConfigurableGameTypes {
+		if gameType == known {
+			return gameType, true
+		}
+	}
+
+	c.JSON(http.StatusNotFound, gin.H{"error": "Unknown game type"})
+	return "", false
+}
@@ -0,0 +1,601 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+// GetPlayer is the support view of one player: profile, role, account
+// standing, wallet and logged-in sessions.
+func (h *AdminHandler) GetPlayer(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	user, err := h.redisService.GetUser(userID)
+	if err == redis.Nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Player not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get player",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	wallet, err := h.redisService.GetWallet(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get wallet",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	state, err := h.redisService.GetAccountState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get account state",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	role, err := h.roleService.GetRole(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get role",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	sessions, err := h.redisService.ListUserSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	sessionList := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		sessionList = append(sessionList, gin.H{
+			"session_id":    session.SessionID,
+			"user_agent":    session.UserAgent,
+			"ip":            session.IP,
+			"created_at":    session.CreatedAt,
+			"last_accessed": session.LastAccessed,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"user":    user,
+		"role":    role,
+		"account": state,
+		"wallet": gin.H{
+			"balance":       wallet.Balance,
+			"locked":        wallet.LockedBalance,
+			"available":     wallet.Balance - wallet.LockedBalance,
+			"total_wagered": wallet.TotalWagered,
+			"total_won":     wallet.TotalWon,
+			"version":       wallet.Version,
+		},
+		"sessions": sessionList,
+	})
+}
+
+func (h *AdminHandler) GetPlayerGames(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+	limit := limitQuery(c, 50, 100)
+
+	activeIDs, err := h.redisService.GetUserActiveGames(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get active games",
+			"details": err.Error(),
+		})
+		return
+	}
+	active, err := h.redisService.BulkGetGameSessions(activeIDs)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get active games",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	history, err := h.redisService.GetGameHistory(userID, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get game history",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"active":  active,
+		"history": history,
+	})
+}
+
+func (h *AdminHandler) GetPlayerTransactions(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	transactions, err := h.redisService.GetUserTransactions(userID, limitQuery(c, 50, 100))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get transactions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"transactions": transactions,
+		"count":        len(transactions),
+	})
+}
+
+func (h *AdminHandler) GetPlayerBetPatterns(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	patterns, err := h.redisService.GetBetPatterns(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get bet patterns",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"patterns": patterns,
+		"count":    len(patterns),
+	})
+}
+
+// AdjustBalance credits or debits a player's balance. The reason is kept on
+// the player's transaction and in the audit log. Credits above the approval
+// threshold are only requested here and wait for a second admin.
+func (h *AdminHandler) AdjustBalance(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.BalanceAdjustmentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if req.Amount > h.creditApprovalThreshold {
+		h.requestAdjustment(c, userID, &req)
+		return
+	}
+
+	wallet, err := h.redisService.AdjustWalletBalance(userID, req.Amount, req.Reason)
+	if err != nil && wallet == nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to adjust balance",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Balance adjusted for user %d but transaction not recorded: %v", userID, err)
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditAdjustBalance,
+		TargetUserID: userID,
+		Reason:       req.Reason,
+		Details: map[string]interface{}{
+			"amount":        req.Amount,
+			"balance_after": wallet.Balance,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"balance": wallet.Balance,
+		"version": wallet.Version,
+	})
+}
+
+func (h *AdminHandler) requestAdjustment(c *gin.Context, userID int64, req *models.BalanceAdjustmentRequest) {
+	adjustment := &models.PendingAdjustment{
+		UserID:            userID,
+		Amount:            req.Amount,
+		Reason:            req.Reason,
+		RequestedBy:       c.GetInt64("user_id"),
+		RequestedByAPIKey: c.GetString("api_key_id"),
+	}
+	if err := h.redisService.RequestAdjustment(adjustment); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to request adjustment",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditRequestAdjustment,
+		TargetUserID: userID,
+		Reason:       req.Reason,
+		Details: map[string]interface{}{
+			"adjustment_id": adjustment.ID,
+			"amount":        req.Amount,
+		},
+	})
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success":          true,
+		"pending_approval": true,
+		"adjustment":       adjustment,
+	})
+}
+
+func (h *AdminHandler) ListPendingAdjustments(c *gin.Context) {
+	adjustments, err := h.redisService.ListPendingAdjustments()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list adjustments",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"adjustments": adjustments,
+		"count":       len(adjustments),
+	})
+}
+
+// ApproveAdjustment applies a held credit. The approver must be an admin
+// other than the one who requested it.
+func (h *AdminHandler) ApproveAdjustment(c *gin.Context) {
+	adjustment, ok := h.claimAdjustment(c)
+	if !ok {
+		return
+	}
+
+	wallet, err := h.redisService.AdjustWalletBalance(adjustment.UserID, adjustment.Amount, adjustment.Reason)
+	if err != nil && wallet == nil {
+		// Nothing was credited; put the request back for another try
+		restoreErr := h.redisService.RestorePendingAdjustment(adjustment)
+		if restoreErr != nil {
+			log.Printf("Adjustment %s failed and could not be restored: %v", adjustment.ID, restoreErr)
+		}
+
+		h.audit(c, &models.AuditEntry{
+			Action:       models.AuditApproveAdjustmentFailed,
+			TargetUserID: adjustment.UserID,
+			Reason:       adjustment.Reason,
+			Details: map[string]interface{}{
+				"adjustment_id": adjustment.ID,
+				"amount":        adjustment.Amount,
+				"requested_by":  adjustment.RequestedBy,
+				"error":         err.Error(),
+				"restored":      restoreErr == nil,
+			},
+		})
+
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to adjust balance",
+			"details": err.Error(),
+		})
+		return
+	}
+	if err != nil {
+		log.Printf("Balance adjusted for user %d but transaction not recorded: %v", adjustment.UserID, err)
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditApproveAdjustment,
+		TargetUserID: adjustment.UserID,
+		Reason:       adjustment.Reason,
+		Details: map[string]interface{}{
+			"adjustment_id":        adjustment.ID,
+			"amount":               adjustment.Amount,
+			"requested_by":         adjustment.RequestedBy,
+			"requested_by_api_key": adjustment.RequestedByAPIKey,
+			"balance_after":        wallet.Balance,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"balance": wallet.Balance,
+		"version": wallet.Version,
+	})
+}
+
+func (h *AdminHandler) RejectAdjustment(c *gin.Context) {
+	var req models.AccountActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	adjustment, ok := h.claimAdjustment(c)
+	if !ok {
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditRejectAdjustment,
+		TargetUserID: adjustment.UserID,
+		Reason:       req.Reason,
+		Details: map[string]interface{}{
+			"adjustment_id": adjustment.ID,
+			"amount":        adjustment.Amount,
+			"requested_by":  adjustment.RequestedBy,
+		},
+	})
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// claimAdjustment takes the adjustment named in the path off the pending
+// list, refusing the admin who requested it.
+func (h *AdminHandler) claimAdjustment(c *gin.Context) (*models.PendingAdjustment, bool) {
+	adjustment, err := h.redisService.GetPendingAdjustment(c.Param("id"))
+	if err == services.ErrAdjustmentNotFound {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment not found or expired"})
+		return nil, false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get adjustment",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+
+	if adjustment.RequestedBy != 0 && adjustment.RequestedBy == c.GetInt64("user_id") {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Adjustments need a second admin"})
+		return nil, false
+	}
+
+	claimed, err := h.redisService.ClaimPendingAdjustment(adjustment.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to claim adjustment",
+			"details": err.Error(),
+		})
+		return nil, false
+	}
+	if !claimed {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Adjustment not found or expired"})
+		return nil, false
+	}
+
+	return adjustment, true
+}
+
+func (h *AdminHandler) FreezeAccount(c *gin.Context) {
+	h.setAccountStatus(c, models.AccountFrozen, models.AuditFreezeAccount)
+}
+
+func (h *AdminHandler) UnfreezeAccount(c *gin.Context) {
+	h.setAccountStatus(c, models.AccountActive, models.AuditUnfreezeAccount)
+}
+
+// SetAccountStatus moves a player to any account status. Closing an
+// account also logs the player out everywhere.
+func (h *AdminHandler) SetAccountStatus(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.SetAccountStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.applyAccountStatus(c, userID, req.Status, req.Reason, models.AuditSetAccountStatus)
+}
+
+func (h *AdminHandler) setAccountStatus(c *gin.Context, status models.AccountStatus, action models.AuditAction) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	var req models.AccountActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.applyAccountStatus(c, userID, status, req.Reason, action)
+}
+
+func (h *AdminHandler) applyAccountStatus(c *gin.Context, userID int64, status models.AccountStatus, reason string, action models.AuditAction) {
+	current, err := h.redisService.GetAccountState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get account",
+			"details": err.Error(),
+		})
+		return
+	}
+	// Support and API keys can freeze and unfreeze, but not undo a hold an
+	// admin placed
+	role, _ := c.Get("role")
+	actorRole, _ := role.(models.Role)
+	if current.Status.AdminHold() && status != current.Status && actorRole != models.RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Insufficient role",
+			"details": "only an admin can change a " + string(current.Status) + " account",
+		})
+		return
+	}
+
+	state := &models.AccountState{
+		UserID:    userID,
+		Status:    status,
+		Reason:    reason,
+		ChangedBy: c.GetInt64("user_id"),
+		ChangedAt: time.Now(),
+	}
+	if err := h.redisService.SetAccountState(state); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update account",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	details := map[string]interface{}{"status": status, "previous_status": current.Status}
+	if !status.CanLogin() {
+		revoked, err := h.redisService.DeleteAllUserSessions(userID)
+		if err != nil {
+			log.Printf("Account %d closed but sessions not revoked: %v", userID, err)
+		}
+		details["revoked_sessions"] = revoked
+	}
+	if !status.CanMoveMoney() {
+		refunded, err := h.gameEngine.RefundUserGames(userID)
+		if err != nil {
+			log.Printf("Account %d restricted but games not all refunded: %v", userID, err)
+		}
+		details["refunded_games"] = refunded
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       action,
+		TargetUserID: userID,
+		Reason:       reason,
+		Details:      details,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"account": state,
+	})
+}
+
+// RevokeSessions logs the player out everywhere.
+func (h *AdminHandler) RevokeSessions(c *gin.Context) {
+	userID, ok := userIDParam(c)
+	if !ok {
+		return
+	}
+
+	count, err := h.redisService.DeleteAllUserSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to revoke sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	h.audit(c, &models.AuditEntry{
+		Action:       models.AuditRevokeSessions,
+		TargetUserID: userID,
+		Details:      map[string]interface{}{"revoked": count},
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"revoked": count,
+	})
+}
+
+// GetAuditLog lists staff actions, newest first. ?user_id narrows it to
+// one player and ?before pages back from an entry ID.
+func (h *AdminHandler) GetAuditLog(c *gin.Context) {
+	var userID int64
+	if raw := c.Query("user_id"); raw != "" {
+		id, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+			return
+		}
+		userID = id
+	}
+
+	entries, err := h.redisService.ReadAuditLog(userID, c.Query("before"), limitQuery(c, 50, 500))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to read audit log",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
+
+// audit records who did what. The action has already happened, so a failed
+// write is logged rather than reported to the caller.
+func (h *AdminHandler) audit(c *gin.Context, entry *models.AuditEntry) {
+	entry.ActorID = c.GetInt64("user_id")
+	role, _ := c.Get("role")
+	entry.ActorRole, _ = role.(models.Role)
+	entry.APIKeyID = c.GetString("api_key_id")
+	entry.IP = c.ClientIP()
+
+	if err := h.redisService.WriteAuditEntry(entry); err != nil {
+		log.Printf("AUDIT WRITE FAILED for %s by %d (api key %q) on user %d: %v",
+			entry.Action, entry.ActorID, entry.APIKeyID, entry.TargetUserID, err)
+	}
+}
+
+func limitQuery(c *gin.Context, def, max int64) int64 {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", strconv.FormatInt(def, 10)), 10, 64)
+	if err != nil || limit <= 0 || limit > max {
+		return def
+	}
+	return limit
+}
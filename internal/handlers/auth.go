@@ -2,13 +2,17 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 
+	"sample-miniapp-backend/internal/config"
 	"sample-miniapp-backend/internal/models"
 	"sample-miniapp-backend/internal/services"
 	"sample-miniapp-backend/internal/utils"
@@ -17,14 +21,18 @@ import (
 type AuthHandler struct {
 	redisService *services.RedisService
 	jwtService   *services.JWTService
+	roleService  *services.RoleService
 	botToken     string
+	telegram     config.TelegramConfig
 }
 
-func NewAuthHandler(redisService *services.RedisService, jwtService *services.JWTService, botToken string) *AuthHandler {
+func NewAuthHandler(redisService *services.RedisService, jwtService *services.JWTService, roleService *services.RoleService, botToken string, telegram config.TelegramConfig) *AuthHandler {
 	return &AuthHandler{
 		redisService: redisService,
 		jwtService:   jwtService,
+		roleService:  roleService,
 		botToken:     botToken,
+		telegram:     telegram,
 	}
 }
 
@@ -37,53 +45,290 @@ func (h *AuthHandler) Authenticate(c *gin.Context) {
 
 	queryStr := c.Request.URL.RawQuery
 
-	valid, err := utils.ValidateTelegramInitData(h.botToken, queryStr)
+	replayKey, valid, err := h.validateInitData(queryStr)
 	if err != nil || !valid {
 		log.Println("The error", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Telegram initData"})
 		return
 	}
 
-	isFresh, err := utils.CheckInitDataAge(queryStr)
+	isFresh, err := utils.CheckInitDataAge(queryStr, h.telegram.InitDataMaxAge)
 	if err != nil || !isFresh {
 		log.Println("Second error", err)
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "InitData expired"})
 		return
 	}
 
+	// Each initData buys one session. Once it is past its max age the age
+	// check rejects it, so the record only has to outlive that.
+	authDate, _ := utils.InitDataAuthDate(queryStr)
+	ttl := time.Until(authDate.Add(h.telegram.InitDataMaxAge))
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	fresh, err := h.redisService.MarkInitDataUsed(replayKey, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check initData"})
+		return
+	}
+	if !fresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "InitData already used"})
+		return
+	}
+
 	var telegramUser models.TelegramUser
 	if err := json.Unmarshal([]byte(initData.User), &telegramUser); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user data"})
 		return
 	}
 
+	if !h.checkCanLogin(c, telegramUser.ID) {
+		return
+	}
+
+	h.startSession(c, &telegramUser, replayKey)
+}
+
+// AuthenticateWidget logs in the browser version with the user object the
+// Telegram Login Widget passes to its callback. It starts the same kind of
+// session as Authenticate, for the same Telegram user ID and so the same
+// wallet.
+func (h *AuthHandler) AuthenticateWidget(c *gin.Context) {
+	body, err := c.GetRawData()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid login payload"})
+		return
+	}
+
+	payload, err := utils.LoginWidgetPayload(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid login payload",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	valid, err := utils.ValidateTelegramLoginWidget(h.botToken, payload)
+	if err != nil || !valid {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid Telegram login"})
+		return
+	}
+
+	isFresh, err := utils.CheckInitDataAge(payload, h.telegram.LoginWidgetMaxAge)
+	if err != nil || !isFresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login expired"})
+		return
+	}
+
+	fields, _ := url.ParseQuery(payload)
+	hash := fields.Get("hash")
+
+	authDate, _ := utils.InitDataAuthDate(payload)
+	ttl := time.Until(authDate.Add(h.telegram.LoginWidgetMaxAge))
+	if ttl < time.Second {
+		ttl = time.Second
+	}
+	fresh, err := h.redisService.MarkInitDataUsed(hash, ttl)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check login"})
+		return
+	}
+	if !fresh {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Login already used"})
+		return
+	}
+
+	userID, err := strconv.ParseInt(fields.Get("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user data"})
+		return
+	}
+
+	// The widget does not send everything initData does, and leaves out
+	// optional fields the user has not set, so only overwrite what it sent
+	// and keep the rest of what the Mini App stored before
+	telegramUser := models.TelegramUser{ID: userID}
+	if stored, err := h.redisService.GetUser(userID); err == nil {
+		telegramUser = *stored
+	}
+	for key, field := range map[string]*string{
+		"first_name": &telegramUser.FirstName,
+		"last_name":  &telegramUser.LastName,
+		"username":   &telegramUser.Username,
+		"photo_url":  &telegramUser.PhotoURL,
+	} {
+		if fields.Has(key) {
+			*field = fields.Get(key)
+		}
+	}
+
+	if !h.checkCanLogin(c, telegramUser.ID) {
+		return
+	}
+
+	h.startSession(c, &telegramUser, hash)
+}
+
+// startSession stores the user and a new login session, and responds with
+// its tokens.
+func (h *AuthHandler) startSession(c *gin.Context, telegramUser *models.TelegramUser, initDataHash string) {
 	sessionID := uuid.New().String()
 
 	userSession := &models.UserSession{
-		TelegramUser: telegramUser,
+		TelegramUser: *telegramUser,
 		SessionID:    sessionID,
-		InitDataHash: initData.Hash,
+		InitDataHash: initDataHash,
+		UserAgent:    c.Request.UserAgent(),
+		IP:           c.ClientIP(),
 		CreatedAt:    time.Now(),
 		LastAccessed: time.Now(),
 	}
 
-	if err := h.redisService.StoreUser(&telegramUser); err != nil {
+	if err := h.redisService.StoreUser(telegramUser); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store user data"})
 		return
 	}
 
-	if err := h.redisService.StoreUserSession(userSession, 24*time.Hour); err != nil {
+	if err := h.redisService.StoreUserSession(userSession, h.jwtService.RefreshExpiry()); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store session"})
 		return
 	}
 
-	authResponse, err := h.jwtService.GenerateToken(telegramUser.ID, sessionID)
+	authResponse, err := h.issueTokens(telegramUser.ID, sessionID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
 		return
 	}
 
-	authResponse.User = &telegramUser
+	authResponse.User = telegramUser
+
+	c.JSON(http.StatusOK, authResponse)
+}
+
+// Refresh trades a refresh token for a new access token and a new refresh
+// token, and slides the session forward. Replaying a spent refresh token
+// ends the session.
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	record, err := h.redisService.RotateRefreshToken(req.RefreshToken)
+	if errors.Is(err, services.ErrRefreshTokenInvalid) || errors.Is(err, services.ErrRefreshTokenReused) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token"})
+		return
+	}
+
+	if !h.checkCanLogin(c, record.UserID) {
+		return
+	}
+
+	alive, err := h.redisService.TouchUserSession(record.UserID, record.SessionID, h.jwtService.RefreshExpiry())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+		return
+	}
+	if !alive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or logged out"})
+		return
+	}
+
+	authResponse, err := h.issueTokens(record.UserID, record.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token"})
+		return
+	}
 
 	c.JSON(http.StatusOK, authResponse)
 }
+
+// validateInitData accepts initData signed for this bot, or, when Telegram's
+// public key is configured, initData carrying a valid third-party signature
+// for one of the trusted bots.
+func (h *AuthHandler) validateInitData(queryStr string) (replayKey string, valid bool, err error) {
+	valid, err = utils.ValidateTelegramInitData(h.botToken, queryStr)
+	if err == nil && valid {
+		// hash is the HMAC itself, so it is covered by the check
+		parsed, _ := url.ParseQuery(queryStr)
+		return parsed.Get("hash"), true, nil
+	}
+
+	if h.telegram.PublicKey == nil {
+		return "", valid, err
+	}
+	for _, botID := range h.telegram.ThirdPartyBotIDs {
+		valid, err = utils.ValidateTelegramSignature(h.telegram.PublicKey, botID, queryStr)
+		if err != nil {
+			return "", false, err
+		}
+		if valid {
+			// Never the hash, which the signature does not cover
+			replayKey, err = utils.SignedInitDataDigest(botID, queryStr)
+			if err != nil {
+				return "", false, err
+			}
+			return replayKey, true, nil
+		}
+	}
+
+	return "", false, nil
+}
+
+// checkCanLogin refuses closed accounts. Frozen and under review players
+// may still log in to see their history.
+func (h *AuthHandler) checkCanLogin(c *gin.Context, userID int64) bool {
+	state, err := h.redisService.GetAccountState(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check account"})
+		return false
+	}
+	if !state.Status.CanLogin() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":   "Account closed",
+			"details": state.Reason,
+		})
+		return false
+	}
+	return true
+}
+
+// issueTokens looks the role up afresh, so a role change reaches the user's
+// tokens by the next refresh at the latest.
+func (h *AuthHandler) issueTokens(userID int64, sessionID string) (*models.AuthResponse, error) {
+	role, err := h.roleService.GetRole(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	authResponse, err := h.jwtService.GenerateToken(userID, sessionID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshExpiry := h.jwtService.RefreshExpiry()
+	refreshToken, err := h.redisService.IssueRefreshToken(userID, sessionID, refreshExpiry)
+	if err != nil {
+		return nil, err
+	}
+
+	authResponse.RefreshToken = refreshToken
+	authResponse.RefreshExpires = time.Now().Add(refreshExpiry).Unix()
+	return authResponse, nil
+}
+
+// JWKS publishes the public keys for verifying access tokens. It is empty
+// when tokens are signed with the HS256 shared secret.
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{"keys": h.jwtService.JWKS()})
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+type FeedHandler struct {
+	redisService *services.RedisService
+}
+
+func NewFeedHandler(redisService *services.RedisService) *FeedHandler {
+	return &FeedHandler{
+		redisService: redisService,
+	}
+}
+
+// GetFeed returns recent settled bets. ?type=high_rollers narrows it to
+// large bets and big wins.
+func (h *FeedHandler) GetFeed(c *gin.Context) {
+	feed := models.FeedType(c.DefaultQuery("type", string(models.FeedAll)))
+	if feed != models.FeedAll && feed != models.FeedHighRollers {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown feed type"})
+		return
+	}
+
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "20"), 10, 64)
+	if err != nil || limit <= 0 {
+		limit = 20
+	}
+
+	entries, err := h.redisService.GetFeed(feed, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get feed",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"type":    feed,
+		"entries": entries,
+		"count":   len(entries),
+	})
+}
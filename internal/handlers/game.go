@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
@@ -13,6 +13,13 @@ import (
 	"sample-miniapp-backend/internal/services"
 )
 
+// Per-user limits per minute, shared by the REST and WebSocket commands.
+const (
+	rateLimitBets     = 30
+	rateLimitCashouts = 60
+	rateLimitReveals  = 120
+)
+
 type GameHandler struct {
 	gameEngine   *services.GameEngine
 	redisService *services.RedisService
@@ -38,7 +45,7 @@ func (h *GameHandler) PlaceBet(c *gin.Context) {
 	}
 
 	// Rate Limit: 30 bets per minute
-	allowed, err := h.redisService.CheckRateLimit(userID, "bet", 30, 1*time.Minute)
+	allowed, err := h.redisService.CheckRateLimit(userID, "bet", rateLimitBets, 1*time.Minute)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rate limit check failed"})
 		return
@@ -55,16 +62,10 @@ func (h *GameHandler) PlaceBet(c *gin.Context) {
 		return
 	}
 
-	if req.Amount > 10000 {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Maximum bet is 10000 cents ($100)",
-		})
-		return
-	}
-
+	// Per-game bet limits come from the live game config, checked by the engine
 	session, err := h.gameEngine.PlaceBet(c.Request.Context(), userID, &req)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(statusForGameError(err), gin.H{
 			"error":   "Failed to place bet",
 			"details": err.Error(),
 		})
@@ -73,18 +74,7 @@ func (h *GameHandler) PlaceBet(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"game": gin.H{
-			"id":          session.ID,
-			"game_type":   session.GameType,
-			"bet_amount":  session.BetAmount,
-			"multiplier":  session.Multiplier,
-			"server_hash": session.ServerHash,
-			"nonce":       session.Nonce,
-			"client_seed": session.ClientSeed,
-			"crash_point": session.CrashPoint,
-			"status":      session.Status,
-			"created_at":  session.CreatedAt,
-		},
+		"game":    betResponse(session),
 	})
 }
 
@@ -101,7 +91,7 @@ func (h *GameHandler) Cashout(c *gin.Context) {
 	}
 
 	// Rate Limit: 60 cashouts per minute
-	allowed, err := h.redisService.CheckRateLimit(userID, "cashout", 60, 1*time.Minute)
+	allowed, err := h.redisService.CheckRateLimit(userID, "cashout", rateLimitCashouts, 1*time.Minute)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rate limit check failed"})
 		return
@@ -113,7 +103,7 @@ func (h *GameHandler) Cashout(c *gin.Context) {
 
 	result, err := h.gameEngine.Cashout(c.Request.Context(), userID, req.GameID)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(statusForGameError(err), gin.H{
 			"error":   "Failed to cashout",
 			"details": err.Error(),
 		})
@@ -149,6 +139,7 @@ func (h *GameHandler) GetBalance(c *gin.Context) {
 			"nonce":         wallet.Nonce,
 			"client_seed":   wallet.ClientSeed,
 			"server_hash":   wallet.ServerHash,
+			"version":       wallet.Version,
 		},
 	})
 }
@@ -167,17 +158,7 @@ func (h *GameHandler) GetActiveGames(c *gin.Context) {
 
 	var response []gin.H
 	for _, game := range games {
-		response = append(response, gin.H{
-			"id":          game.ID,
-			"game_type":   game.GameType,
-			"bet_amount":  game.BetAmount,
-			"multiplier":  game.Multiplier,
-			"crash_point": game.CrashPoint,
-			"cashout_at":  game.CashoutAt,
-			"status":      game.Status,
-			"created_at":  game.CreatedAt,
-			"updated_at":  game.UpdatedAt,
-		})
+		response = append(response, activeGameResponse(game))
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -207,21 +188,13 @@ func (h *GameHandler) GetGameHistory(c *gin.Context) {
 
 	var response []gin.H
 	for _, game := range games {
-		result := "lose"
-		payout := 0.0
-
-		if game.Status == "cashed_out" && game.CashoutAt > 0 {
-			result = "win"
-			payout = game.BetAmount * game.CashoutAt
-		}
-
 		response = append(response, gin.H{
 			"id":         game.ID,
 			"game_type":  game.GameType,
 			"bet_amount": game.BetAmount,
 			"multiplier": game.CashoutAt,
-			"payout":     payout,
-			"result":     result,
+			"payout":     game.Payout(),
+			"result":     game.Result(),
 			"status":     game.Status,
 			"created_at": game.CreatedAt,
 			"ended_at":   game.EndedAt,
@@ -265,12 +238,15 @@ func (h *GameHandler) GetVerificationData(c *gin.Context) {
 	})
 }
 
+// VerifyGame recomputes a result from revealed seeds. With game_id the seeds
+// and nonce are taken from the stored game, including archived ones.
 func (h *GameHandler) VerifyGame(c *gin.Context) {
 	var req struct {
-		ClientSeed string `json:"client_seed" binding:"required"`
+		GameID     string `json:"game_id"`
+		ClientSeed string `json:"client_seed"`
 		ServerSeed string `json:"server_seed" binding:"required"`
-		Nonce      int64  `json:"nonce" binding:"required"`
-		GameType   string `json:"game_type" binding:"required"`
+		Nonce      int64  `json:"nonce"`
+		GameType   string `json:"game_type"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -281,11 +257,51 @@ func (h *GameHandler) VerifyGame(c *gin.Context) {
 		return
 	}
 
-	crashPoint, hash, err := h.gameEngine.VerifyGameResult(
-		req.ClientSeed,
-		req.ServerSeed,
-		req.Nonce,
-	)
+	var session *models.GameSession
+	if req.GameID != "" {
+		var err error
+		session, err = h.redisService.GetGameSession(req.GameID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error":   "Game not found",
+				"details": err.Error(),
+			})
+			return
+		}
+
+		if session.UserID != c.GetInt64("user_id") {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You don't own this game"})
+			return
+		}
+
+		req.ClientSeed = session.ClientSeed
+		req.Nonce = session.Nonce
+		req.GameType = string(session.GameType)
+	} else if req.ClientSeed == "" || req.GameType == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "client_seed and game_type are required without game_id",
+		})
+		return
+	}
+
+	var crashPoint float64
+	var hash string
+	var err error
+	if session != nil && session.MaxMultiplier > 0 {
+		crashPoint, hash, err = h.gameEngine.VerifyCrashPoint(
+			req.ClientSeed,
+			req.ServerSeed,
+			req.Nonce,
+			session.HouseEdge,
+			session.MaxMultiplier,
+		)
+	} else {
+		crashPoint, hash, err = h.gameEngine.VerifyGameResult(
+			req.ClientSeed,
+			req.ServerSeed,
+			req.Nonce,
+		)
+	}
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -295,17 +311,32 @@ func (h *GameHandler) VerifyGame(c *gin.Context) {
 		return
 	}
 
+	verification := gin.H{
+		"valid":           true,
+		"crash_point":     crashPoint,
+		"calculated_hash": hash,
+		"game_type":       req.GameType,
+		"client_seed":     req.ClientSeed,
+		"server_seed":     req.ServerSeed,
+		"nonce":           req.Nonce,
+	}
+
+	if session != nil {
+		seedMatches := services.HashServerSeed(req.ServerSeed) == session.ServerHash
+		hashMatches := session.FinalHash == "" || session.FinalHash == hash
+
+		verification["game_id"] = session.ID
+		verification["server_hash"] = session.ServerHash
+		verification["server_seed_matches"] = seedMatches
+		verification["valid"] = seedMatches && hashMatches
+		if session.GameType == models.GameTypeCrash {
+			verification["recorded_crash_point"] = session.CrashPoint
+		}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"verification": gin.H{
-			"valid":           true,
-			"crash_point":     crashPoint,
-			"calculated_hash": hash,
-			"game_type":       req.GameType,
-			"client_seed":     req.ClientSeed,
-			"server_seed":     req.ServerSeed,
-			"nonce":           req.Nonce,
-		},
+		"success":      true,
+		"verification": verification,
 	})
 }
 
@@ -326,7 +357,7 @@ func (h *GameHandler) RevealMine(c *gin.Context) {
 	}
 
 	// Rate Limit: 120 reveals per minute
-	allowed, err := h.redisService.CheckRateLimit(userID, "reveal", 120, 1*time.Minute)
+	allowed, err := h.redisService.CheckRateLimit(userID, "reveal", rateLimitReveals, 1*time.Minute)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rate limit check failed"})
 		return
@@ -336,152 +367,18 @@ func (h *GameHandler) RevealMine(c *gin.Context) {
 		return
 	}
 
-	session, err := h.redisService.GetGameSession(req.GameID)
+	result, err := h.gameEngine.RevealMine(c.Request.Context(), userID, req.GameID, req.Position)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Game not found",
+		c.JSON(statusForGameError(err), gin.H{
+			"error":   "Failed to reveal tile",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	if session.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "You don't own this game",
-		})
-		return
-	}
-
-	if session.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":  "Game is not active",
-			"status": session.Status,
-		})
-		return
-	}
-
-	metadata := session.Metadata
-
-	minePositionsRaw, ok := metadata["mines"]
-	if !ok {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Mine data missing",
-		})
-		return
-	}
-
-	minePositions := make([]int, 0)
-	if mines, ok := minePositionsRaw.([]interface{}); ok {
-		for _, m := range mines {
-			if pos, ok := m.(float64); ok {
-				minePositions = append(minePositions, int(pos))
-			}
-		}
-	}
-
-	revealedRaw, _ := metadata["revealed"]
-	revealed := make([]int, 0)
-	if rev, ok := revealedRaw.([]interface{}); ok {
-		for _, r := range rev {
-			if pos, ok := r.(float64); ok {
-				revealed = append(revealed, int(pos))
-			}
-		}
-	}
-
-	for _, pos := range revealed {
-		if pos == req.Position {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": "Position already revealed",
-			})
-			return
-		}
-	}
-
-	isMine := false
-	for _, minePos := range minePositions {
-		if minePos == req.Position {
-			isMine = true
-			break
-		}
-	}
-
-	revealed = append(revealed, req.Position)
-	metadata["revealed"] = revealed
-
-	multipliers := map[int]float64{
-		0:  1.0,
-		1:  1.12,
-		2:  1.3,
-		3:  1.62,
-		4:  2.08,
-		5:  2.85,
-		6:  4.14,
-		7:  6.5,
-		8:  11.5,
-		9:  24.0,
-		10: 75.0,
-		11: 750.0,
-	}
-
-	revealedCount := len(revealed)
-	multiplier := multipliers[revealedCount]
-
-	if isMine {
-		session.Status = "lost"
-		session.EndedAt = time.Now()
-
-		h.redisService.ReleaseBalanceFromGame(
-			userID,
-			session.BetAmount,
-			false, // lost
-			0,     // no winnings
-		)
-
-		wallet, err := h.redisService.GetWallet(userID)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error":  "Wallet error active",
-				"status": session.Status,
-			})
-		}
-
-		h.redisService.SaveTransaction(&models.Transaction{
-			ID:            generateTransactionID(),
-			UserID:        userID,
-			Type:          models.TransactionTypeBet,
-			Amount:        -session.BetAmount,
-			BalanceBefore: wallet.Balance,
-			BalanceAfter:  0,
-			GameID:        session.ID,
-			Description:   fmt.Sprintf("Lost mines game at position %d", req.Position),
-			CreatedAt:     time.Now(),
-		})
-	} else {
-		session.Multiplier = multiplier
-		session.Metadata = metadata
-	}
-	h.redisService.UpdateGameSession(session)
-
-	response := gin.H{
-		"game_id":        session.ID,
-		"is_mine":        isMine,
-		"position":       req.Position,
-		"multiplier":     multiplier,
-		"revealed":       revealed,
-		"revealed_count": revealedCount,
-		"mines_left":     len(minePositions),
-		"game_over":      isMine,
-		"status":         session.Status,
-	}
-
-	if isMine {
-		response["mine_positions"] = minePositions
-	}
-
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"result":  response,
+		"result":  revealResponse(result),
 	})
 }
 
@@ -500,110 +397,18 @@ func (h *GameHandler) CashoutMines(c *gin.Context) {
 		return
 	}
 
-	session, err := h.redisService.GetGameSession(req.GameID)
+	result, err := h.gameEngine.CashoutMines(c.Request.Context(), userID, req.GameID)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{
-			"error":   "Game not found",
-			"details": err.Error(),
-		})
-		return
-	}
-
-	if session.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{
-			"error": "You don't own this game",
-		})
-		return
-	}
-
-	// Check game status
-	if session.Status != "active" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":  "Game is not active",
-			"status": session.Status,
-		})
-		return
-	}
-
-	metadata := session.Metadata
-
-	revealedRaw, _ := metadata["revealed"]
-	revealedCount := 0
-	if rev, ok := revealedRaw.([]interface{}); ok {
-		revealedCount = len(rev)
-	}
-
-	multipliers := map[int]float64{
-		0:  1.0,
-		1:  1.12,
-		2:  1.3,
-		3:  1.62,
-		4:  2.08,
-		5:  2.85,
-		6:  4.14,
-		7:  6.5,
-		8:  11.5,
-		9:  24.0,
-		10: 75.0,
-		11: 750.0,
-	}
-
-	multiplier := multipliers[revealedCount]
-	winnings := session.BetAmount * multiplier
-	session.Status = "cashed_out"
-	session.CashoutAt = multiplier
-	session.Multiplier = multiplier
-	session.EndedAt = time.Now()
-
-	err = h.redisService.ReleaseBalanceFromGame(
-		userID,
-		session.BetAmount,
-		true,     // won
-		winnings, // net winnings
-	)
-
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
+		c.JSON(statusForGameError(err), gin.H{
 			"error":   "Failed to process cashout",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	h.redisService.UpdateGameSession(session)
-	h.redisService.CompleteGameSession(userID, req.GameID)
-
-	wallet, err := h.redisService.GetWallet(userID)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error":  "Wallet error active",
-			"status": session.Status,
-		})
-	}
-
-	h.redisService.SaveTransaction(&models.Transaction{
-		ID:            generateTransactionID(),
-		UserID:        userID,
-		Type:          models.TransactionTypeWin,
-		Amount:        winnings,
-		BalanceBefore: wallet.Balance,
-		BalanceAfter:  0,
-		GameID:        session.ID,
-		Description:   fmt.Sprintf("Mines cashout at %.2fx with %d reveals", multiplier, revealedCount),
-		CreatedAt:     time.Now(),
-	})
-
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
-		"result": gin.H{
-			"game_id":        session.ID,
-			"multiplier":     multiplier,
-			"bet_amount":     session.BetAmount,
-			"winnings":       winnings,
-			"revealed_count": revealedCount,
-			"new_balance":    wallet.Balance,
-			"status":         session.Status,
-		},
+		"result":  minesCashoutResponse(result),
 	})
 }
 
@@ -625,7 +430,7 @@ func (h *GameHandler) PlayDice(c *gin.Context) {
 	}
 
 	// Rate Limit: 30 bets per minute (same as PlaceBet)
-	allowed, err := h.redisService.CheckRateLimit(userID, "bet", 30, 1*time.Minute)
+	allowed, err := h.redisService.CheckRateLimit(userID, "bet", rateLimitBets, 1*time.Minute)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Rate limit check failed"})
 		return
@@ -637,36 +442,119 @@ func (h *GameHandler) PlayDice(c *gin.Context) {
 
 	result, err := h.gameEngine.PlayDice(c.Request.Context(), userID, req.GameID, req.Target, req.Over)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{
+		c.JSON(statusForGameError(err), gin.H{
 			"error":   "Failed to play dice",
 			"details": err.Error(),
 		})
 		return
 	}
 
-	wallet, err := h.redisService.GetWallet(userID)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"result":  diceResponse(h.redisService, userID, result, req.Over),
+	})
+}
+
+func activeGameResponse(game *models.GameSession) gin.H {
+	return gin.H{
+		"id":          game.ID,
+		"game_type":   game.GameType,
+		"bet_amount":  game.BetAmount,
+		"multiplier":  game.Multiplier,
+		"crash_point": game.CrashPoint,
+		"cashout_at":  game.CashoutAt,
+		"status":      game.Status,
+		"created_at":  game.CreatedAt,
+		"updated_at":  game.UpdatedAt,
+	}
+}
+
+func betResponse(session *models.GameSession) gin.H {
+	return gin.H{
+		"id":          session.ID,
+		"game_type":   session.GameType,
+		"bet_amount":  session.BetAmount,
+		"multiplier":  session.Multiplier,
+		"server_hash": session.ServerHash,
+		"nonce":       session.Nonce,
+		"client_seed": session.ClientSeed,
+		"crash_point": session.CrashPoint,
+		"status":      session.Status,
+		"created_at":  session.CreatedAt,
+	}
+}
+
+func revealResponse(result *models.MinesRevealResponse) gin.H {
+	response := gin.H{
+		"game_id":        result.GameID,
+		"is_mine":        result.IsMine,
+		"position":       result.Position,
+		"multiplier":     result.Multiplier,
+		"revealed":       result.Positions,
+		"revealed_count": len(result.Positions),
+		"mines_left":     result.MineCount,
+		"game_over":      result.GameOver,
+		"status":         result.Status,
+	}
+
+	if result.IsMine {
+		response["mine_positions"] = result.MinePositions
+	}
+
+	return response
+}
+
+func minesCashoutResponse(result *models.MinesCashoutResponse) gin.H {
+	return gin.H{
+		"game_id":        result.GameID,
+		"multiplier":     result.Multiplier,
+		"bet_amount":     result.BetAmount,
+		"winnings":       result.Payout,
+		"revealed_count": result.RevealedCount,
+		"new_balance":    result.NewBalance,
+		"status":         result.Status,
+	}
+}
+
+func diceResponse(redisService *services.RedisService, userID int64, result *models.DicePlayResponse, over bool) gin.H {
+	var balance float64
+	wallet, err := redisService.GetWallet(userID)
 	if err != nil {
 		// Should not happen as PlayDice succeeds
 		log.Printf("Failed to get wallet after dice play: %v", err)
+	} else {
+		balance = wallet.Balance
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"success": true,
-		"result": gin.H{
-			"game_id":     result.GameID,
-			"roll":        result.Roll,
-			"target":      result.Target,
-			"over":        req.Over,
-			"win":         result.Win,
-			"multiplier":  result.Multiplier,
-			"bet_amount":  0, // TODO: Add BetAmount to response model if needed by frontend
-			"payout":      result.Payout,
-			"new_balance": wallet.Balance,
-			"status":      "completed",
-		},
-	})
+	status := models.GameStatusLost
+	if result.Win {
+		status = models.GameStatusWon
+	}
+
+	return gin.H{
+		"game_id":     result.GameID,
+		"roll":        result.Roll,
+		"target":      result.Target,
+		"over":        over,
+		"win":         result.Win,
+		"multiplier":  result.Multiplier,
+		"bet_amount":  0, // TODO: Add BetAmount to response model if needed by frontend
+		"payout":      result.Payout,
+		"new_balance": balance,
+		"status":      status,
+	}
 }
 
-func generateTransactionID() string {
-	return fmt.Sprintf("tx_%d", time.Now().UnixNano())
+// statusForGameError maps engine errors to HTTP codes. A rejected status
+// transition means the game was already settled by something else.
+func statusForGameError(err error) int {
+	switch {
+	case errors.Is(err, services.ErrGameNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrNotGameOwner), errors.Is(err, services.ErrAccountRestricted):
+		return http.StatusForbidden
+	case errors.Is(err, models.ErrInvalidTransition), errors.Is(err, services.ErrGameChanged):
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
 }
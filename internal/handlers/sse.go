@@ -0,0 +1,226 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+// IssueEventsTicket returns a short-lived ticket for /api/events, since the
+// browser's native EventSource cannot send an Authorization header. The
+// ticket works until it expires, so EventSource can reconnect with it.
+func (h *WebSocketHandler) IssueEventsTicket(c *gin.Context) {
+	role, _ := c.Get("role")
+	roleValue, _ := role.(models.Role)
+
+	ticket, err := h.redisService.CreateStreamTicket(&models.StreamTicket{
+		UserID:    c.GetInt64("user_id"),
+		SessionID: c.GetString("session_id"),
+		Role:      roleValue,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to issue stream ticket",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"ticket":     ticket,
+		"expires_in": int(services.TTLStreamTicket.Seconds()),
+	})
+}
+
+// HandleEvents streams the same messages as the WebSocket over Server-Sent
+// Events, for clients behind proxies that break sockets. The client is
+// subscribed to its user topic and the global feed, plus any topics listed
+// in ?topics=a,b.
+//
+// Each event on those topics carries an id holding the last seq seen per
+// topic. A reconnect with Last-Event-ID (or ?last_event_id= for a new
+// EventSource) replays what was missed, the same way RESUME does.
+func (h *WebSocketHandler) HandleEvents(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	topics := []string{UserTopic(userID), TopicGlobal}
+	for _, topic := range strings.Split(c.Query("topics"), ",") {
+		if topic = strings.TrimSpace(topic); topic == "" {
+			continue
+		}
+		if err := h.authorizeTopic(&Client{UserID: userID}, topic); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Cannot subscribe to topic",
+				"details": err.Error(),
+			})
+			return
+		}
+		topics = append(topics, topic)
+	}
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	// positions tracks the stream's own topics; a zero has not seen a seq
+	positions := make(map[string]int64, len(topics))
+	missed := make(map[string]int64)
+	previous := parseEventID(lastEventID)
+	for _, topic := range topics {
+		positions[topic] = previous[topic]
+		if seq, ok := previous[topic]; ok {
+			missed[topic] = seq
+		}
+	}
+
+	if err := h.hub.reserve(userID, h.config.MaxConnsPerUser, h.config.MaxConns); err != nil {
+		status := http.StatusTooManyRequests
+		if err == errHubFull {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	defer h.hub.release(userID)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // stop nginx from buffering the stream
+	c.Status(http.StatusOK)
+
+	// No socket: this handler drains the send queue instead of writePump
+	client := newClient(userID, nil, h.config.PingInterval)
+
+	// Hold before registering, which subscribes the user and global topics,
+	// so no live message overtakes the replay
+	for topic := range missed {
+		h.hub.holdTopic(client, topic)
+	}
+
+	h.hub.register <- client
+	defer func() {
+		h.hub.unregister <- client
+		client.Close()
+	}()
+
+	for _, topic := range topics[2:] {
+		h.hub.Subscribe(client, topic)
+	}
+	h.sendBalance(client)
+
+	// Runs alongside the loop below, which drains what it sends
+	if len(missed) > 0 {
+		go h.replay(client, missed, false)
+	}
+
+	rc := http.NewResponseController(c.Writer)
+	ticker := time.NewTicker(h.config.PingInterval)
+	defer ticker.Stop()
+
+	for {
+		var frame string
+		select {
+		case f := <-client.send:
+			frame = fmt.Sprintf("data: %s\n\n", f.data)
+			if trackEventPosition(positions, f.data) {
+				frame = "id: " + formatEventID(positions) + "\n" + frame
+			}
+		case <-ticker.C:
+			// Comment line; keeps proxies from timing out an idle stream
+			frame = ": ping\n\n"
+		case <-client.done:
+			return
+		case <-c.Request.Context().Done():
+			return
+		}
+
+		rc.SetWriteDeadline(time.Now().Add(writeWait))
+		if _, err := c.Writer.WriteString(frame); err != nil {
+			return
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// trackEventPosition records the seq of a message on one of the stream's
+// topics, or the seqs a SNAPSHOT resets them to. It reports whether
+// positions changed.
+func trackEventPosition(positions map[string]int64, data []byte) bool {
+	var message struct {
+		Type  string          `json:"type"`
+		Topic string          `json:"topic"`
+		Seq   int64           `json:"seq"`
+		Data  json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(data, &message); err != nil {
+		return false
+	}
+
+	if message.Type == "SNAPSHOT" {
+		var snapshot struct {
+			Seqs map[string]int64 `json:"seqs"`
+		}
+		if err := json.Unmarshal(message.Data, &snapshot); err != nil {
+			return false
+		}
+		changed := false
+		for topic, seq := range snapshot.Seqs {
+			if _, ok := positions[topic]; ok {
+				positions[topic] = seq
+				changed = true
+			}
+		}
+		return changed
+	}
+
+	if message.Seq == 0 {
+		return false
+	}
+	if _, ok := positions[message.Topic]; !ok {
+		return false
+	}
+	positions[message.Topic] = message.Seq
+	return true
+}
+
+// formatEventID encodes the last seq per topic as "topic=seq,topic=seq".
+// Topics never contain commas, since ?topics= is split on them.
+func formatEventID(positions map[string]int64) string {
+	parts := make([]string, 0, len(positions))
+	for topic, seq := range positions {
+		if seq > 0 {
+			parts = append(parts, topic+"="+strconv.FormatInt(seq, 10))
+		}
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+func parseEventID(id string) map[string]int64 {
+	positions := make(map[string]int64)
+	for _, part := range strings.Split(id, ",") {
+		i := strings.LastIndex(part, "=")
+		if i <= 0 {
+			continue
+		}
+		seq, err := strconv.ParseInt(part[i+1:], 10, 64)
+		if err != nil || seq <= 0 {
+			continue
+		}
+		positions[part[:i]] = seq
+	}
+	return positions
+}
@@ -48,8 +48,20 @@ func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 		}
 	}
 
+	state, err := h.redisService.GetAccountState(userID.(int64))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get account state"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"user": session.TelegramUser,
+		"account": gin.H{
+			"status":         state.Status,
+			"reason":         state.Reason,
+			"can_move_money": state.Status.CanMoveMoney(),
+			"changed_at":     state.ChangedAt,
+		},
 		"session": gin.H{
 			"session_id":    session.SessionID,
 			"created_at":    session.CreatedAt,
@@ -86,3 +98,122 @@ func (h *UserHandler) Logout(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Successfully logged out"})
 }
+
+// ListSessions shows every device the user is logged in on.
+func (h *UserHandler) ListSessions(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	currentID := c.GetString("session_id")
+
+	sessions, err := h.redisService.ListUserSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list sessions",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	response := make([]gin.H, 0, len(sessions))
+	for _, session := range sessions {
+		response = append(response, gin.H{
+			"session_id":    session.SessionID,
+			"user_agent":    session.UserAgent,
+			"ip":            session.IP,
+			"created_at":    session.CreatedAt,
+			"last_accessed": session.LastAccessed,
+			"current":       session.SessionID == currentID,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"sessions": response,
+		"count":    len(response),
+	})
+}
+
+// RevokeSession logs out a single device. Its access and refresh tokens stop
+// working immediately.
+func (h *UserHandler) RevokeSession(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+	sessionID := c.Param("id")
+
+	alive, err := h.redisService.SessionExists(userID, sessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+	if !alive {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Session not found"})
+		return
+	}
+
+	if err := h.redisService.DeleteUserSession(userID, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke session"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RevokeAllSessions logs the user out everywhere, including this device.
+func (h *UserHandler) RevokeAllSessions(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	count, err := h.redisService.DeleteAllUserSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"revoked": count,
+	})
+}
+
+func (h *UserHandler) GetPrivacy(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	hidden, err := h.redisService.IsFeedOptOut(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to get privacy settings",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"hide_from_feed": hidden,
+	})
+}
+
+// UpdatePrivacy opts the player in or out of the public bet feeds. Bets
+// already shown stay until they age out of the feed.
+func (h *UserHandler) UpdatePrivacy(c *gin.Context) {
+	userID := c.GetInt64("user_id")
+
+	var req models.FeedPrivacyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	if err := h.redisService.SetFeedOptOut(userID, req.HideFromFeed); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to update privacy settings",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"hide_from_feed": req.HideFromFeed,
+	})
+}
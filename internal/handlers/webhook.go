@@ -0,0 +1,140 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+type WebhookHandler struct {
+	webhookService *services.WebhookService
+	redisService   *services.RedisService
+}
+
+func NewWebhookHandler(webhookService *services.WebhookService, redisService *services.RedisService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+		redisService:   redisService,
+	}
+}
+
+func (h *WebhookHandler) ListSubscriptions(c *gin.Context) {
+	subs, err := h.redisService.ListWebhooks()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list webhooks",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	redacted := make([]*models.WebhookSubscription, len(subs))
+	for i, sub := range subs {
+		redacted[i] = sub.Redacted()
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"webhooks": redacted,
+		"count":    len(redacted),
+	})
+}
+
+// CreateSubscription is the only response that includes the signing secret.
+func (h *WebhookHandler) CreateSubscription(c *gin.Context) {
+	var req models.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid request",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	sub, err := h.webhookService.CreateSubscription(&req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Failed to create webhook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"webhook": sub,
+	})
+}
+
+func (h *WebhookHandler) DeleteSubscription(c *gin.Context) {
+	if err := h.redisService.DeleteWebhook(c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to delete webhook",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// ListDeliveries returns recent deliveries; ?status=dead shows the DLQ.
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+	if err != nil {
+		limit = 50
+	}
+
+	deadOnly := c.Query("status") == string(models.WebhookDeliveryDead)
+
+	deliveries, err := h.redisService.ListWebhookDeliveries(deadOnly, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":   "Failed to list deliveries",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"deliveries": deliveries,
+		"count":      len(deliveries),
+	})
+}
+
+func (h *WebhookHandler) GetDelivery(c *gin.Context) {
+	delivery, err := h.redisService.GetWebhookDelivery(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Delivery not found",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"delivery": delivery,
+	})
+}
+
+func (h *WebhookHandler) ReplayDelivery(c *gin.Context) {
+	delivery, err := h.webhookService.ReplayDelivery(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error":   "Failed to replay delivery",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"delivery": delivery,
+	})
+}
@@ -1,83 +1,126 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"log"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
 
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/middleware"
+	"sample-miniapp-backend/internal/models"
 	"sample-miniapp-backend/internal/services"
 )
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true
-	},
-}
-
 type WebSocketHandler struct {
 	gameEngine   *services.GameEngine
 	redisService *services.RedisService
 	hub          *WebSocketHub
-}
-
-type WebSocketHub struct {
-	clients    map[int64]*websocket.Conn
-	register   chan *Client
-	unregister chan *Client
-	broadcast  chan *Message
-}
-
-type Client struct {
-	UserID int64
-	Conn   *websocket.Conn
+	config       config.WebSocketConfig
+	upgrader     websocket.Upgrader
 }
 
 type Message struct {
-	Type   string      `json:"type"`
-	UserID int64       `json:"user_id,omitempty"`
-	GameID string      `json:"game_id,omitempty"`
-	Data   interface{} `json:"data"`
+	Type      string      `json:"type"`
+	RequestID string      `json:"request_id,omitempty"` // echoed on command replies
+	Topic     string      `json:"topic,omitempty"`
+	Seq       int64       `json:"seq,omitempty"` // per-topic, for RESUME
+	UserID    int64       `json:"user_id,omitempty"`
+	GameID    string      `json:"game_id,omitempty"`
+	Data      interface{} `json:"data"`
+
+	closeTopic bool      // drop the topic's subscriptions once delivered
+	tick       *gameTick // set on GAME_UPDATE, for binary subscribers
 }
 
-func NewWebSocketHandler(gameEngine *services.GameEngine, redisService *services.RedisService) *WebSocketHandler {
-	hub := &WebSocketHub{
-		clients:    make(map[int64]*websocket.Conn),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
-		broadcast:  make(chan *Message, 100),
-	}
-
+func NewWebSocketHandler(gameEngine *services.GameEngine, redisService *services.RedisService, cfg config.WebSocketConfig) *WebSocketHandler {
+	hub := newWebSocketHub(redisService)
 	go hub.run()
 
-	return &WebSocketHandler{
+	h := &WebSocketHandler{
 		gameEngine:   gameEngine,
 		redisService: redisService,
 		hub:          hub,
+		config:       cfg,
+	}
+	h.upgrader = websocket.Upgrader{
+		CheckOrigin: h.checkOrigin,
+		// Only one protocol is echoed back; prefer binary ticks when offered.
+		// The bearer token is read from the offered list either way.
+		Subprotocols: []string{BinaryTickProtocol, middleware.WebSocketAuthProtocol},
 	}
+
+	return h
+}
+
+// checkOrigin allows origins on the allowlist. Without one configured only
+// same-origin upgrades are accepted.
+func (h *WebSocketHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		// Not a browser; auth still applies
+		return true
+	}
+
+	if len(h.config.AllowedOrigins) == 0 {
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+
+	for _, allowed := range h.config.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
 }
 
 func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 	userID := c.GetInt64("user_id")
+	if userID == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization required"})
+		return
+	}
 
-	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	// Check caps before upgrading so the client gets a real HTTP status
+	if err := h.hub.reserve(userID, h.config.MaxConnsPerUser, h.config.MaxConns); err != nil {
+		status := http.StatusTooManyRequests
+		if err == errHubFull {
+			status = http.StatusServiceUnavailable
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+	defer h.hub.release(userID)
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
 	if err != nil {
 		log.Printf("Failed to upgrade to WebSocket: %v", err)
 		return
 	}
 
-	client := &Client{
-		UserID: userID,
-		Conn:   conn,
-	}
+	conn.SetReadLimit(h.config.MaxMessageBytes)
+	conn.SetReadDeadline(time.Now().Add(h.config.PongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(h.config.PongTimeout))
+	})
+
+	client := newClient(userID, conn, h.config.PingInterval)
+	client.binaryTicks = conn.Subprotocol() == BinaryTickProtocol
+	go client.writePump()
 
 	h.hub.register <- client
 
 	defer func() {
 		h.hub.unregister <- client
-		conn.Close()
+		client.Close()
 	}()
 
 	h.sendBalance(client)
@@ -91,24 +134,40 @@ func (h *WebSocketHandler) HandleWebSocket(c *gin.Context) {
 			}
 			break
 		}
+		conn.SetReadDeadline(time.Now().Add(h.config.PongTimeout))
 
-		h.handleMessage(client, &msg)
+		h.handleMessage(c.Request.Context(), client, &msg)
 	}
 }
 
-func (h *WebSocketHandler) handleMessage(client *Client, msg *Message) {
+func (h *WebSocketHandler) handleMessage(ctx context.Context, client *Client, msg *Message) {
+	if isGameCommand(msg.Type) {
+		h.handleCommand(ctx, client, msg)
+		return
+	}
+
 	switch msg.Type {
 	case "PING":
 		h.sendPong(client)
+	case "RESUME":
+		h.resume(client, msg.Data)
+	case "SUBSCRIBE":
+		if topic, ok := msg.Data.(string); ok {
+			h.subscribe(client, topic)
+		}
+	case "UNSUBSCRIBE":
+		if topic, ok := msg.Data.(string); ok {
+			h.unsubscribe(client, topic)
+		}
 	case "SUBSCRIBE_GAME":
 		// Subscribe to game updates
 		if gameID, ok := msg.Data.(string); ok {
-			h.subscribeToGame(client, gameID)
+			h.subscribe(client, GameTopic(gameID))
 		}
 	case "UNSUBSCRIBE_GAME":
 		// Unsubscribe from game updates
 		if gameID, ok := msg.Data.(string); ok {
-			h.unsubscribeFromGame(client, gameID)
+			h.unsubscribe(client, GameTopic(gameID))
 		}
 	}
 }
@@ -120,18 +179,31 @@ func (h *WebSocketHandler) sendBalance(client *Client) {
 		return
 	}
 
-	msg := Message{
-		Type: "BALANCE_UPDATE",
+	client.Send(balanceMessage(wallet, "connected"))
+}
+
+// BroadcastBalance pushes a wallet change to every connection the user has,
+// on any instance. Clients keep the highest version they have seen.
+func (h *WebSocketHandler) BroadcastBalance(wallet *models.Wallet, reason string) {
+	msg := balanceMessage(wallet, reason)
+	msg.Topic = UserTopic(wallet.UserID)
+	h.hub.Publish(msg)
+}
+
+func balanceMessage(wallet *models.Wallet, reason string) *Message {
+	return &Message{
+		Type:   "BALANCE_UPDATE",
+		UserID: wallet.UserID,
 		Data: gin.H{
 			"balance":       wallet.Balance,
 			"locked":        wallet.LockedBalance,
 			"available":     wallet.Balance - wallet.LockedBalance,
 			"total_wagered": wallet.TotalWagered,
 			"total_won":     wallet.TotalWon,
+			"version":       wallet.Version,
+			"reason":        reason,
 		},
 	}
-
-	client.Conn.WriteJSON(msg)
 }
 
 func (h *WebSocketHandler) sendPong(client *Client) {
@@ -142,73 +214,109 @@ func (h *WebSocketHandler) sendPong(client *Client) {
 		},
 	}
 
-	client.Conn.WriteJSON(msg)
+	client.Send(msg)
 }
 
-func (h *WebSocketHandler) subscribeToGame(client *Client, gameID string) {
-	// Subscribe logic here
-	// You'll want to track which clients are watching which games
-}
+func (h *WebSocketHandler) subscribe(client *Client, topic string) {
+	if err := h.authorizeTopic(client, topic); err != nil {
+		h.sendError(client, "", "SUBSCRIBE", http.StatusForbidden, err.Error())
+		return
+	}
 
-func (h *WebSocketHandler) unsubscribeFromGame(client *Client, gameID string) {
-	// Unsubscribe logic here
+	h.hub.Subscribe(client, topic)
+	client.Send(Message{Type: "SUBSCRIBED", Topic: topic, Data: topic})
 }
 
-func (hub *WebSocketHub) run() {
-	for {
-		select {
-		case client := <-hub.register:
-			hub.clients[client.UserID] = client.Conn
-			log.Printf("Client registered: %d", client.UserID)
-
-		case client := <-hub.unregister:
-			if _, ok := hub.clients[client.UserID]; ok {
-				delete(hub.clients, client.UserID)
-				log.Printf("Client unregistered: %d", client.UserID)
-			}
+func (h *WebSocketHandler) unsubscribe(client *Client, topic string) {
+	h.hub.Unsubscribe(client, topic)
+	client.Send(Message{Type: "UNSUBSCRIBED", Topic: topic, Data: topic})
+}
 
-		case message := <-hub.broadcast:
-			hub.broadcastMessage(message)
-		}
+// authorizeTopic keeps private topics private: a game room is only open to
+// the player who owns the game, a user topic only to that user.
+func (h *WebSocketHandler) authorizeTopic(client *Client, topic string) error {
+	kind, id, err := parseTopic(topic)
+	if err != nil {
+		return err
 	}
-}
 
-func (hub *WebSocketHub) broadcastMessage(message *Message) {
-	if message.UserID != 0 {
-		if conn, ok := hub.clients[message.UserID]; ok {
-			conn.WriteJSON(message)
+	switch kind {
+	case "game":
+		session, err := h.redisService.GetGameSession(id)
+		if err != nil {
+			return fmt.Errorf("game not found")
 		}
-	} else {
-		for _, conn := range hub.clients {
-			conn.WriteJSON(message)
+		if session.UserID != client.UserID {
+			return fmt.Errorf("you don't own this game")
+		}
+	case "user":
+		if id != strconv.FormatInt(client.UserID, 10) {
+			return fmt.Errorf("cannot subscribe to another user")
 		}
 	}
+
+	return nil
+}
+
+func (h *WebSocketHandler) sendError(client *Client, requestID, command string, code int, reason string) {
+	client.Send(Message{
+		Type:      "ERROR",
+		RequestID: requestID,
+		Data: gin.H{
+			"command": command,
+			"code":    code,
+			"error":   reason,
+		},
+	})
 }
 
 func (h *WebSocketHandler) BroadcastGameUpdate(gameID string, multiplier float64) {
+	now := time.Now()
 	msg := &Message{
 		Type:   "GAME_UPDATE",
+		Topic:  GameTopic(gameID),
 		GameID: gameID,
 		Data: gin.H{
-			"game_id":    gameID,
-			"multiplier": multiplier,
-			"timestamp":  time.Now().Unix(),
+			"game_id":      gameID,
+			"multiplier":   multiplier,
+			"timestamp":    now.Unix(),
+			"timestamp_ms": now.UnixMilli(),
 		},
 	}
+	msg.tick = &gameTick{GameID: gameID, Multiplier: multiplier, TimestampMs: now.UnixMilli()}
 
-	h.hub.broadcast <- msg
+	h.hub.Publish(msg)
 }
 
 func (h *WebSocketHandler) BroadcastGameCrash(gameID string, crashPoint float64) {
 	msg := &Message{
 		Type:   "GAME_CRASH",
+		Topic:  GameTopic(gameID),
 		GameID: gameID,
 		Data: gin.H{
 			"game_id":     gameID,
 			"crash_point": crashPoint,
 			"timestamp":   time.Now().Unix(),
 		},
+		closeTopic: true,
 	}
 
-	h.hub.broadcast <- msg
+	h.hub.Publish(msg)
+}
+
+// BroadcastFeed publishes a settled bet to the all-bets feed and, when it
+// qualifies, to the high rollers feed.
+func (h *WebSocketHandler) BroadcastFeed(entry *models.FeedEntry) {
+	h.hub.Publish(&Message{Type: "BET_FEED", Topic: TopicFeedAll, Data: entry})
+	if entry.IsHighRoller() {
+		h.hub.Publish(&Message{Type: "BET_FEED", Topic: TopicFeedHighRollers, Data: entry})
+	}
+}
+
+func (h *WebSocketHandler) JoinGame(userID int64, gameID string) {
+	h.hub.PublishJoin(userID, GameTopic(gameID))
+}
+
+func (h *WebSocketHandler) CloseGame(gameID string) {
+	h.hub.Publish(&Message{Topic: GameTopic(gameID), closeTopic: true})
 }
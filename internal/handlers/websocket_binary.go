@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+// BinaryTickProtocol is the subprotocol a client offers to receive crash
+// ticks as compact binary frames. Everything else stays JSON text.
+const BinaryTickProtocol = "ticks.bin.v1"
+
+const (
+	frameTypeTick   byte = 0x01
+	tickFrameHeader      = 25
+)
+
+// gameTick is the typed form of a GAME_UPDATE. It rides alongside the JSON
+// message, across the backplane too, so binary frames never need the JSON
+// parsed back.
+type gameTick struct {
+	GameID      string  `json:"game_id"`
+	Multiplier  float64 `json:"multiplier"`
+	TimestampMs int64   `json:"timestamp_ms"`
+}
+
+// encodeTickFrame turns a tick and its topic seq into a binary frame:
+//
+//	byte  0      frame type (0x01 = tick)
+//	bytes 1-8    topic seq, uint64 big endian
+//	bytes 9-16   multiplier, float64 big endian
+//	bytes 17-24  server time, unix milliseconds, int64 big endian
+//	bytes 25-    game ID, ASCII
+func encodeTickFrame(seq int64, tick *gameTick) []byte {
+	frame := make([]byte, tickFrameHeader+len(tick.GameID))
+	frame[0] = frameTypeTick
+	binary.BigEndian.PutUint64(frame[1:9], uint64(seq))
+	binary.BigEndian.PutUint64(frame[9:17], math.Float64bits(tick.Multiplier))
+	binary.BigEndian.PutUint64(frame[17:25], uint64(tick.TimestampMs))
+	copy(frame[tickFrameHeader:], tick.GameID)
+
+	return frame
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// clientSendQueue is how many outbound frames a connection may have
+	// pending before it is treated as a slow consumer and dropped.
+	clientSendQueue = 256
+	writeWait       = 10 * time.Second
+)
+
+// Client is one WebSocket connection or SSE stream. A user may hold several
+// at once (tabs, devices). All writes go through send so only writePump, or
+// the SSE handler when Conn is nil, ever touches the connection's writer.
+type Client struct {
+	UserID int64
+	Conn   *websocket.Conn
+	topics map[string]bool // guarded by the hub's mutex
+	// held queues live messages per topic while a RESUME replays it;
+	// guarded by the hub's mutex
+	held map[string][]heldMessage
+
+	send         chan frame
+	done         chan struct{}
+	closeOnce    sync.Once
+	pingInterval time.Duration
+	binaryTicks  bool // negotiated BinaryTickProtocol
+}
+
+type heldMessage struct {
+	seq  int64
+	data []byte
+}
+
+type frame struct {
+	data   []byte
+	binary bool
+}
+
+func newClient(userID int64, conn *websocket.Conn, pingInterval time.Duration) *Client {
+	return &Client{
+		UserID:       userID,
+		Conn:         conn,
+		topics:       make(map[string]bool),
+		send:         make(chan frame, clientSendQueue),
+		done:         make(chan struct{}),
+		pingInterval: pingInterval,
+	}
+}
+
+// Send queues a message without blocking. If the queue is full the client is
+// closed rather than stalling the caller.
+func (c *Client) Send(msg interface{}) bool {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Failed to marshal WS message: %v", err)
+		return false
+	}
+	return c.sendRaw(data)
+}
+
+func (c *Client) sendRaw(data []byte) bool {
+	return c.sendFrame(frame{data: data})
+}
+
+func (c *Client) sendFrame(f frame) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+
+	select {
+	case c.send <- f:
+		return true
+	default:
+		log.Printf("Dropping slow WS client for user %d", c.UserID)
+		c.Close()
+		return false
+	}
+}
+
+// Close stops the writer, which closes the underlying connection and in
+// turn ends the reader loop. Safe to call more than once.
+func (c *Client) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+	})
+}
+
+// writePump is the only writer on the connection. It also sends ping
+// frames; the reader's pong handler extends the read deadline.
+func (c *Client) writePump() {
+	ticker := time.NewTicker(c.pingInterval)
+	defer func() {
+		ticker.Stop()
+		c.Conn.Close()
+	}()
+
+	for {
+		select {
+		case <-ticker.C:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.Close()
+				return
+			}
+		case f := <-c.send:
+			messageType := websocket.TextMessage
+			if f.binary {
+				messageType = websocket.BinaryMessage
+			}
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.Conn.WriteMessage(messageType, f.data); err != nil {
+				c.Close()
+				return
+			}
+		case <-c.done:
+			c.Conn.SetWriteDeadline(time.Now().Add(writeWait))
+			c.Conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			return
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+// Game commands a client can send over the socket instead of calling REST.
+// Each must carry a request_id; the reply is an ACK or ERROR echoing it.
+const (
+	CommandPlaceBet = "PLACE_BET"
+	CommandCashout  = "CASHOUT"
+	CommandReveal   = "REVEAL"
+	CommandPlayDice = "PLAY_DICE"
+)
+
+func isGameCommand(msgType string) bool {
+	switch msgType {
+	case CommandPlaceBet, CommandCashout, CommandReveal, CommandPlayDice:
+		return true
+	}
+	return false
+}
+
+// handleCommand runs a game command at most once per request ID. A retry of
+// a completed command gets the original ACK back instead of running again.
+func (h *WebSocketHandler) handleCommand(ctx context.Context, client *Client, msg *Message) {
+	if msg.RequestID == "" {
+		h.sendError(client, "", msg.Type, http.StatusBadRequest, "request_id is required")
+		return
+	}
+
+	cached, fresh, err := h.redisService.BeginIdempotentRequest(client.UserID, msg.RequestID)
+	if errors.Is(err, services.ErrRequestInProgress) {
+		h.sendError(client, msg.RequestID, msg.Type, http.StatusConflict, err.Error())
+		return
+	}
+	if err != nil {
+		log.Printf("Idempotency check failed: %v", err)
+		h.sendError(client, msg.RequestID, msg.Type, http.StatusInternalServerError, "Idempotency check failed")
+		return
+	}
+	if !fresh {
+		client.sendRaw(cached)
+		return
+	}
+
+	result, status, err := h.runCommand(ctx, client.UserID, msg)
+	if err != nil {
+		h.redisService.AbandonIdempotentRequest(client.UserID, msg.RequestID)
+		h.sendError(client, msg.RequestID, msg.Type, status, err.Error())
+		return
+	}
+
+	data, err := json.Marshal(Message{
+		Type:      "ACK",
+		RequestID: msg.RequestID,
+		Data: gin.H{
+			"command": msg.Type,
+			"result":  result,
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to marshal WS ack: %v", err)
+		return
+	}
+
+	if err := h.redisService.CompleteIdempotentRequest(client.UserID, msg.RequestID, data); err != nil {
+		log.Printf("Failed to store WS ack: %v", err)
+	}
+	client.sendRaw(data)
+}
+
+// runCommand applies the same validation and rate limits as the REST
+// handlers and returns the same result shapes.
+func (h *WebSocketHandler) runCommand(ctx context.Context, userID int64, msg *Message) (interface{}, int, error) {
+	switch msg.Type {
+	case CommandPlaceBet:
+		var req models.BetRequest
+		if err := decodeCommand(msg.Data, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		if status, err := h.checkRateLimit(userID, "bet", rateLimitBets); err != nil {
+			return nil, status, err
+		}
+
+		session, err := h.gameEngine.PlaceBet(ctx, userID, &req)
+		if err != nil {
+			return nil, statusForGameError(err), err
+		}
+		return betResponse(session), http.StatusOK, nil
+
+	case CommandCashout:
+		var req models.CashoutRequest
+		if err := decodeCommand(msg.Data, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+
+		session, err := h.redisService.GetGameSession(req.GameID)
+		if err != nil {
+			return nil, http.StatusNotFound, services.ErrGameNotFound
+		}
+
+		if session.GameType == models.GameTypeMines {
+			result, err := h.gameEngine.CashoutMines(ctx, userID, req.GameID)
+			if err != nil {
+				return nil, statusForGameError(err), err
+			}
+			return minesCashoutResponse(result), http.StatusOK, nil
+		}
+
+		if status, err := h.checkRateLimit(userID, "cashout", rateLimitCashouts); err != nil {
+			return nil, status, err
+		}
+		result, err := h.gameEngine.Cashout(ctx, userID, req.GameID)
+		if err != nil {
+			return nil, statusForGameError(err), err
+		}
+		return result, http.StatusOK, nil
+
+	case CommandReveal:
+		var req models.MinesRevealRequest
+		if err := decodeCommand(msg.Data, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		if status, err := h.checkRateLimit(userID, "reveal", rateLimitReveals); err != nil {
+			return nil, status, err
+		}
+
+		result, err := h.gameEngine.RevealMine(ctx, userID, req.GameID, req.Position)
+		if err != nil {
+			return nil, statusForGameError(err), err
+		}
+		return revealResponse(result), http.StatusOK, nil
+
+	case CommandPlayDice:
+		var req models.DicePlayRequest
+		if err := decodeCommand(msg.Data, &req); err != nil {
+			return nil, http.StatusBadRequest, err
+		}
+		if status, err := h.checkRateLimit(userID, "bet", rateLimitBets); err != nil {
+			return nil, status, err
+		}
+
+		result, err := h.gameEngine.PlayDice(ctx, userID, req.GameID, req.Target, req.Over)
+		if err != nil {
+			return nil, statusForGameError(err), err
+		}
+		return diceResponse(h.redisService, userID, result, req.Over), http.StatusOK, nil
+	}
+
+	return nil, http.StatusBadRequest, fmt.Errorf("unknown command: %s", msg.Type)
+}
+
+func (h *WebSocketHandler) checkRateLimit(userID int64, action string, limit int) (int, error) {
+	allowed, err := h.redisService.CheckRateLimit(userID, action, limit, 1*time.Minute)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("rate limit check failed")
+	}
+	if !allowed {
+		return http.StatusTooManyRequests, fmt.Errorf("too many requests, please wait")
+	}
+	return http.StatusOK, nil
+}
+
+// decodeCommand maps a command's data onto a REST request struct and runs
+// the struct's binding rules.
+func decodeCommand(data interface{}, req interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("invalid command data: %v", err)
+	}
+	if err := json.Unmarshal(raw, req); err != nil {
+		return fmt.Errorf("invalid command data: %v", err)
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return fmt.Errorf("invalid command data: %v", err)
+	}
+	return nil
+}
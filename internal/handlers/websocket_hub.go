@@ -0,0 +1,490 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sample-miniapp-backend/internal/services"
+)
+
+const (
+	TopicGlobal          = "global"
+	TopicFeedAll         = "feed:all"
+	TopicFeedHighRollers = "feed:high_rollers"
+	topicPrefixGame      = "game:"
+	topicPrefixRound     = "round:"
+	topicPrefixUser      = "user:"
+)
+
+func GameTopic(gameID string) string   { return topicPrefixGame + gameID }
+func RoundTopic(roundID string) string { return topicPrefixRound + roundID }
+func UserTopic(userID int64) string    { return topicPrefixUser + strconv.FormatInt(userID, 10) }
+
+const (
+	envelopeMessage = "message"
+	envelopeJoin    = "join"
+)
+
+const (
+	hubOutboundQueue = 1024 // envelopes waiting for the backplane
+	hubBatchSize     = 64   // envelopes per Redis round trip
+	hubLogInterval   = 10 * time.Second
+)
+
+// hubEnvelope is what travels over the backplane. Joins share the channel
+// with messages so a player is always in their game room before the first
+// update for it arrives.
+type hubEnvelope struct {
+	Op         string   `json:"op"`
+	Message    *Message `json:"message,omitempty"`
+	CloseTopic bool     `json:"close_topic,omitempty"`
+	UserID     int64    `json:"user_id,omitempty"`
+	Topic      string   `json:"topic,omitempty"`
+
+	// Tick is the typed form of a GAME_UPDATE, for binary subscribers
+	Tick *gameTick `json:"tick,omitempty"`
+}
+
+type WebSocketHub struct {
+	clients    map[int64]map[*Client]bool // every open connection per user
+	topics     map[string]map[*Client]bool
+	mu         sync.RWMutex
+	register   chan *Client
+	unregister chan *Client
+	outbound   chan *hubEnvelope
+
+	overflowLog  *logLimiter
+	backplaneLog *logLimiter
+
+	// Connection counts for the caps, reserved before the upgrade
+	conns      map[int64]int
+	totalConns int
+
+	// backplane fans envelopes out to every instance; nil keeps the hub
+	// local to this process.
+	backplane *services.RedisService
+}
+
+func newWebSocketHub(backplane *services.RedisService) *WebSocketHub {
+	return &WebSocketHub{
+		clients:    make(map[int64]map[*Client]bool),
+		topics:     make(map[string]map[*Client]bool),
+		register:   make(chan *Client),
+		unregister: make(chan *Client),
+		outbound:   make(chan *hubEnvelope, hubOutboundQueue),
+		conns:      make(map[int64]int),
+		backplane:  backplane,
+
+		overflowLog:  &logLimiter{interval: hubLogInterval},
+		backplaneLog: &logLimiter{interval: hubLogInterval},
+	}
+}
+
+var (
+	errUserConnLimit = errors.New("too many connections for this user")
+	errHubFull       = errors.New("server is at its connection limit")
+)
+
+// reserve claims a connection slot for a user, within the per-user and
+// global caps. Every successful reserve must be paired with release.
+func (hub *WebSocketHub) reserve(userID int64, perUser, total int) error {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if hub.totalConns >= total {
+		return errHubFull
+	}
+	if hub.conns[userID] >= perUser {
+		return errUserConnLimit
+	}
+
+	hub.conns[userID]++
+	hub.totalConns++
+	return nil
+}
+
+func (hub *WebSocketHub) release(userID int64) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.totalConns--
+	if hub.conns[userID]--; hub.conns[userID] <= 0 {
+		delete(hub.conns, userID)
+	}
+}
+
+// Publish delivers a message to its topic on every instance. It never
+// blocks; see enqueue for what happens when the backplane falls behind.
+func (hub *WebSocketHub) Publish(message *Message) {
+	hub.enqueue(&hubEnvelope{Op: envelopeMessage, Message: message, CloseTopic: message.closeTopic, Tick: message.tick})
+}
+
+// PublishJoin subscribes a user's connections to a topic on every instance.
+func (hub *WebSocketHub) PublishJoin(userID int64, topic string) {
+	hub.enqueue(&hubEnvelope{Op: envelopeJoin, UserID: userID, Topic: topic})
+}
+
+// enqueue hands an envelope to pump without blocking the game loop or
+// wallet write that produced it. When the queue is full:
+//   - GAME_UPDATE ticks are dropped; the next tick supersedes them
+//   - anything else is delivered to this instance's clients only, without
+//     a seq, the same as when Redis is unreachable
+func (hub *WebSocketHub) enqueue(envelope *hubEnvelope) {
+	select {
+	case hub.outbound <- envelope:
+		return
+	default:
+	}
+
+	if envelope.Op == envelopeMessage && envelope.Message.Type == "GAME_UPDATE" {
+		hub.overflowLog.printf("WS outbound queue full, dropping ticks")
+		return
+	}
+	hub.overflowLog.printf("WS outbound queue full, delivering locally")
+	hub.apply(envelope)
+}
+
+// pump sends outbound envelopes in batches, in the order they were
+// published, so a topic's messages reach the backplane, and therefore every
+// instance, in order. A batch costs two Redis round trips however many
+// messages it holds. Whatever Redis refuses is still delivered locally.
+func (hub *WebSocketHub) pump() {
+	batch := make([]*hubEnvelope, 0, hubBatchSize)
+
+	for envelope := range hub.outbound {
+		batch = append(batch[:0], envelope)
+	drain:
+		for len(batch) < hubBatchSize {
+			select {
+			case envelope := <-hub.outbound:
+				batch = append(batch, envelope)
+			default:
+				break drain
+			}
+		}
+
+		if hub.backplane == nil {
+			for _, envelope := range batch {
+				hub.apply(envelope)
+			}
+			continue
+		}
+		hub.publishBatch(batch)
+	}
+}
+
+func (hub *WebSocketHub) publishBatch(batch []*hubEnvelope) {
+	buffered := hub.sequence(batch)
+
+	envelopes := make([]*hubEnvelope, 0, len(batch))
+	payloads := make([][]byte, 0, len(batch))
+	for _, envelope := range batch {
+		data, err := json.Marshal(envelope)
+		if err != nil {
+			log.Printf("Failed to marshal WS envelope: %v", err)
+			hub.apply(envelope)
+			continue
+		}
+		envelopes = append(envelopes, envelope)
+		payloads = append(payloads, data)
+	}
+
+	published, err := hub.backplane.BufferAndPublish(buffered, payloads)
+	if err != nil {
+		hub.backplaneLog.printf("WS backplane unavailable, delivering locally: %v", err)
+	}
+	for i, envelope := range envelopes {
+		if !published[i] {
+			hub.apply(envelope)
+		}
+	}
+}
+
+// sequence numbers the batch's messages within their topics and returns
+// them for the replay buffer, for clients that reconnect. Close-only
+// messages carry nothing to replay.
+func (hub *WebSocketHub) sequence(batch []*hubEnvelope) []services.TopicMessage {
+	var messages []*Message
+	var topics []string
+	for _, envelope := range batch {
+		if envelope.Op != envelopeMessage || envelope.Message.Type == "" {
+			continue
+		}
+		messages = append(messages, envelope.Message)
+		topics = append(topics, messageTopic(envelope.Message))
+	}
+	if len(messages) == 0 {
+		return nil
+	}
+
+	seqs, err := hub.backplane.NextTopicSeqs(topics)
+	if err != nil {
+		hub.backplaneLog.printf("WS replay: %v", err)
+		return nil
+	}
+
+	buffered := make([]services.TopicMessage, 0, len(messages))
+	for i, message := range messages {
+		message.Topic = topics[i]
+		message.Seq = seqs[i]
+
+		data, err := json.Marshal(message)
+		if err != nil {
+			log.Printf("Failed to marshal WS replay message: %v", err)
+			continue
+		}
+		buffered = append(buffered, services.TopicMessage{Topic: topics[i], Seq: seqs[i], Payload: data})
+	}
+	return buffered
+}
+
+// logLimiter prints at most one line per interval and counts the rest, so a
+// Redis outage does not log once per message.
+type logLimiter struct {
+	mu         sync.Mutex
+	interval   time.Duration
+	last       time.Time
+	suppressed int
+}
+
+func (l *logLimiter) printf(format string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if time.Since(l.last) < l.interval {
+		l.suppressed++
+		return
+	}
+	if l.suppressed > 0 {
+		format += fmt.Sprintf(" (%d similar suppressed)", l.suppressed)
+	}
+	log.Printf(format, args...)
+	l.last = time.Now()
+	l.suppressed = 0
+}
+
+// listen applies envelopes published by any instance, including this one.
+func (hub *WebSocketHub) listen(ctx context.Context) {
+	hub.backplane.SubscribeBackplane(ctx, func(data []byte) {
+		var envelope hubEnvelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			log.Printf("Dropping malformed backplane message: %v", err)
+			return
+		}
+		hub.apply(&envelope)
+	})
+}
+
+func (hub *WebSocketHub) apply(envelope *hubEnvelope) {
+	switch envelope.Op {
+	case envelopeJoin:
+		hub.SubscribeUser(envelope.UserID, envelope.Topic)
+	case envelopeMessage:
+		if envelope.Message == nil {
+			return
+		}
+		envelope.Message.closeTopic = envelope.CloseTopic
+		envelope.Message.tick = envelope.Tick
+		hub.broadcastMessage(envelope.Message)
+	}
+}
+
+func (hub *WebSocketHub) run() {
+	go hub.pump()
+	if hub.backplane != nil {
+		go hub.listen(context.Background())
+	}
+
+	for {
+		select {
+		case client := <-hub.register:
+			hub.mu.Lock()
+			conns, ok := hub.clients[client.UserID]
+			if !ok {
+				conns = make(map[*Client]bool)
+				hub.clients[client.UserID] = conns
+			}
+			conns[client] = true
+			hub.mu.Unlock()
+
+			// Every connection hears about its own account and the public feed
+			hub.Subscribe(client, UserTopic(client.UserID))
+			hub.Subscribe(client, TopicGlobal)
+			log.Printf("Client registered: %d", client.UserID)
+
+		case client := <-hub.unregister:
+			hub.mu.Lock()
+			for topic := range client.topics {
+				hub.removeFromTopic(client, topic)
+			}
+			if conns, ok := hub.clients[client.UserID]; ok && conns[client] {
+				delete(conns, client)
+				if len(conns) == 0 {
+					delete(hub.clients, client.UserID)
+				}
+				log.Printf("Client unregistered: %d", client.UserID)
+			}
+			hub.mu.Unlock()
+		}
+	}
+}
+
+func (hub *WebSocketHub) Subscribe(client *Client, topic string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.addToTopic(client, topic)
+}
+
+func (hub *WebSocketHub) Unsubscribe(client *Client, topic string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.removeFromTopic(client, topic)
+}
+
+// holdTopic subscribes a client to a topic but queues its live messages
+// until releaseTopic, so a replay can be sent first.
+func (hub *WebSocketHub) holdTopic(client *Client, topic string) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	if client.held == nil {
+		client.held = make(map[string][]heldMessage)
+	}
+	if _, ok := client.held[topic]; !ok {
+		client.held[topic] = nil
+	}
+	hub.addToTopic(client, topic)
+}
+
+// releaseTopic sends the messages queued by holdTopic that are newer than
+// afterSeq, the last one replayed, and resumes live delivery.
+func (hub *WebSocketHub) releaseTopic(client *Client, topic string, afterSeq int64) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	for _, message := range client.held[topic] {
+		if message.seq == 0 || message.seq > afterSeq {
+			client.sendRaw(message.data)
+		}
+	}
+	delete(client.held, topic)
+}
+
+// addToTopic expects hub.mu to be held.
+func (hub *WebSocketHub) addToTopic(client *Client, topic string) {
+	subscribers, ok := hub.topics[topic]
+	if !ok {
+		subscribers = make(map[*Client]bool)
+		hub.topics[topic] = subscribers
+	}
+	subscribers[client] = true
+	client.topics[topic] = true
+}
+
+// removeFromTopic expects hub.mu to be held.
+func (hub *WebSocketHub) removeFromTopic(client *Client, topic string) {
+	delete(client.topics, topic)
+	if subscribers, ok := hub.topics[topic]; ok {
+		delete(subscribers, client)
+		if len(subscribers) == 0 {
+			delete(hub.topics, topic)
+		}
+	}
+}
+
+// SubscribeUser joins every connection of a user on this instance to a
+// topic, e.g. their own game room.
+func (hub *WebSocketHub) SubscribeUser(userID int64, topic string) {
+	hub.mu.RLock()
+	clients := make([]*Client, 0, len(hub.clients[userID]))
+	for client := range hub.clients[userID] {
+		clients = append(clients, client)
+	}
+	hub.mu.RUnlock()
+
+	for _, client := range clients {
+		hub.Subscribe(client, topic)
+	}
+}
+
+// messageTopic is where a message is delivered. Messages without a topic
+// fall back to the user's private topic, then to the global feed.
+func messageTopic(message *Message) string {
+	if message.Topic != "" {
+		return message.Topic
+	}
+	if message.UserID != 0 {
+		return UserTopic(message.UserID)
+	}
+	return TopicGlobal
+}
+
+func (hub *WebSocketHub) broadcastMessage(message *Message) {
+	topic := messageTopic(message)
+
+	var data []byte
+	if message.Type != "" {
+		var err error
+		if data, err = json.Marshal(message); err != nil {
+			log.Printf("Failed to marshal WS broadcast: %v", err)
+			return
+		}
+	}
+
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	// sendRaw never blocks, so a slow client cannot hold up the hub
+	if data != nil {
+		var tick []byte
+
+		for client := range hub.topics[topic] {
+			if held, ok := client.held[topic]; ok {
+				client.held[topic] = append(held, heldMessage{seq: message.Seq, data: data})
+				continue
+			}
+			if client.binaryTicks && message.tick != nil {
+				if tick == nil {
+					tick = encodeTickFrame(message.Seq, message.tick)
+				}
+				client.sendFrame(frame{data: tick, binary: true})
+				continue
+			}
+			client.sendRaw(data)
+		}
+	}
+
+	if message.closeTopic {
+		for client := range hub.topics[topic] {
+			delete(client.topics, topic)
+		}
+		delete(hub.topics, topic)
+	}
+}
+
+// parseTopic validates a client-supplied topic name.
+func parseTopic(topic string) (kind, id string, err error) {
+	switch topic {
+	case TopicGlobal:
+		return TopicGlobal, "", nil
+	case TopicFeedAll, TopicFeedHighRollers:
+		return "feed", strings.TrimPrefix(topic, "feed:"), nil
+	}
+
+	for _, prefix := range []string{topicPrefixGame, topicPrefixRound, topicPrefixUser} {
+		if strings.HasPrefix(topic, prefix) && len(topic) > len(prefix) {
+			return strings.TrimSuffix(prefix, ":"), topic[len(prefix):], nil
+		}
+	}
+
+	return "", "", fmt.Errorf("unknown topic: %s", topic)
+}
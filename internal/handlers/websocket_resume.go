@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// resumeRequest is sent by a reconnecting client with the last seq it saw on
+// each topic it cares about.
+type resumeRequest struct {
+	LastSeq map[string]int64 `json:"last_seq"`
+}
+
+// resume re-subscribes a reconnecting client and replays what it missed.
+// When a topic's gap is wider than its replay buffer the client gets a
+// SNAPSHOT of its active games instead and should reset to its seqs.
+func (h *WebSocketHandler) resume(client *Client, data interface{}) {
+	var req resumeRequest
+	if err := decodeCommand(data, &req); err != nil {
+		h.sendError(client, "", "RESUME", http.StatusBadRequest, err.Error())
+		return
+	}
+
+	needSnapshot := false
+	lastSeq := make(map[string]int64, len(req.LastSeq))
+
+	for topic, seq := range req.LastSeq {
+		if err := h.authorizeTopic(client, topic); err != nil {
+			// A finished game's room is gone; the snapshot covers it
+			needSnapshot = true
+			continue
+		}
+
+		// Subscribe before reading the buffer so nothing falls in between,
+		// but hold live messages until the replay is out so the client
+		// sees the topic in order
+		h.hub.holdTopic(client, topic)
+		lastSeq[topic] = seq
+	}
+
+	h.replay(client, lastSeq, needSnapshot)
+}
+
+// replay sends what a client missed on each topic after its last seq, then
+// a SNAPSHOT if some gap could not be filled, then RESUMED. Every topic must
+// already be held with holdTopic; each is released once its replay is out.
+func (h *WebSocketHandler) replay(client *Client, lastSeq map[string]int64, needSnapshot bool) {
+	replayed := 0
+	seqs := make(map[string]int64, len(lastSeq))
+
+	for topic, after := range lastSeq {
+		messages, current, complete, err := h.redisService.ReadTopicMessages(topic, after)
+		if err != nil {
+			log.Printf("WS resume: %v", err)
+			h.hub.releaseTopic(client, topic, 0)
+			needSnapshot = true
+			continue
+		}
+		seqs[topic] = current
+
+		if complete {
+			for _, message := range messages {
+				client.sendRaw(message)
+				replayed++
+			}
+		} else {
+			needSnapshot = true
+		}
+		h.hub.releaseTopic(client, topic, current)
+	}
+
+	if needSnapshot {
+		h.sendSnapshot(client, seqs)
+	}
+
+	client.Send(Message{
+		Type: "RESUMED",
+		Data: gin.H{
+			"replayed": replayed,
+			"snapshot": needSnapshot,
+		},
+	})
+}
+
+func (h *WebSocketHandler) sendSnapshot(client *Client, seqs map[string]int64) {
+	games, err := h.gameEngine.GetUserActiveGames(client.UserID)
+	if err != nil {
+		log.Printf("WS snapshot: %v", err)
+		h.sendError(client, "", "RESUME", http.StatusInternalServerError, "Failed to build snapshot")
+		return
+	}
+
+	active := make([]gin.H, 0, len(games))
+	for _, game := range games {
+		active = append(active, activeGameResponse(game))
+	}
+
+	snapshot := gin.H{
+		"games": active,
+		"seqs":  seqs,
+	}
+	if wallet, err := h.redisService.GetWallet(client.UserID); err == nil {
+		snapshot["balance"] = balanceMessage(wallet, "snapshot").Data
+	}
+
+	client.Send(Message{Type: "SNAPSHOT", Data: snapshot})
+}
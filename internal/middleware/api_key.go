@@ -0,0 +1,105 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+// APIKeyHeader carries the secret of an API key.
+const APIKeyHeader = "X-API-Key"
+
+// APIKeyMiddleware authenticates backend callers by API key instead of a
+// user token. Every call made with a valid key is written to the audit
+// log, including ones refused for IP, rate limit or scope.
+func APIKeyMiddleware(redisService *services.RedisService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		secret := c.GetHeader(APIKeyHeader)
+		if secret == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API key required"})
+			c.Abort()
+			return
+		}
+
+		key, err := redisService.AuthenticateAPIKey(secret)
+		if err == services.ErrAPIKeyInvalid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or revoked API key"})
+			c.Abort()
+			return
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate API key"})
+			c.Abort()
+			return
+		}
+
+		defer auditAPIKeyCall(c, redisService, key)
+
+		if !key.AllowsIP(c.ClientIP()) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "IP address not allowed for this API key"})
+			c.Abort()
+			return
+		}
+
+		allowed, err := redisService.CheckAPIKeyRateLimit(key)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check rate limit"})
+			c.Abort()
+			return
+		}
+		if !allowed {
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"error":       "Rate limit exceeded",
+				"retry_after": 60,
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set("api_key_id", key.ID)
+		c.Set("api_key", key)
+
+		c.Next()
+	}
+}
+
+// RequireScope lets a request through only if its API key has scope. It
+// must run after APIKeyMiddleware.
+func RequireScope(scope models.APIScope) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		value, _ := c.Get("api_key")
+		key, ok := value.(*models.APIKey)
+		if !ok || !key.HasScope(scope) {
+			c.JSON(http.StatusForbidden, gin.H{
+				"error":   "Insufficient scope",
+				"details": "requires " + string(scope),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+func auditAPIKeyCall(c *gin.Context, redisService *services.RedisService, key *models.APIKey) {
+	entry := &models.AuditEntry{
+		APIKeyID: key.ID,
+		Action:   models.AuditAPIKeyCall,
+		IP:       c.ClientIP(),
+		Details: map[string]interface{}{
+			"key_name": key.Name,
+			"method":   c.Request.Method,
+			"path":     c.Request.URL.Path,
+			"status":   c.Writer.Status(),
+		},
+	}
+
+	if err := redisService.WriteAuditEntry(entry); err != nil {
+		log.Printf("AUDIT WRITE FAILED for %s by API key %s: %v", entry.Action, key.ID, err)
+	}
+}
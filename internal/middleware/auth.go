@@ -6,46 +6,150 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 
 	"sample-miniapp-backend/internal/services"
 )
 
-func AuthMiddleware(jwtService *services.JWTService) gin.HandlerFunc {
+func AuthMiddleware(jwtService *services.JWTService, redisService *services.RedisService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
-		var tokenString string
+		if authHeader == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			c.Abort()
+			return
+		}
+
+		parts := strings.SplitN(authHeader, " ", 2)
+		if len(parts) != 2 || parts[0] != "Bearer" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
+			c.Abort()
+			return
+		}
 
-		if authHeader != "" {
+		authenticate(c, jwtService, redisService, parts[1])
+	}
+}
+
+// WebSocketAuthProtocol is the subprotocol a browser offers alongside its
+// token, as in `new WebSocket(url, ["bearer", token])`, since it cannot set
+// an Authorization header on the upgrade request.
+const WebSocketAuthProtocol = "bearer"
+
+// WebSocketAuthMiddleware authenticates an upgrade request from the
+// Authorization header or the Sec-WebSocket-Protocol pair. Tokens are never
+// read from the query string, where they would end up in access logs.
+func WebSocketAuthMiddleware(jwtService *services.JWTService, redisService *services.RedisService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
 			parts := strings.SplitN(authHeader, " ", 2)
 			if len(parts) != 2 || parts[0] != "Bearer" {
 				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
 				c.Abort()
 				return
 			}
-			tokenString = parts[1]
-		} else {
-			tokenString = c.Query("token")
-			if tokenString == "" {
-				c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization header required"})
+			authenticate(c, jwtService, redisService, parts[1])
+			return
+		}
+
+		protocols := websocket.Subprotocols(c.Request)
+		for i, protocol := range protocols {
+			if protocol == WebSocketAuthProtocol && i+1 < len(protocols) {
+				authenticate(c, jwtService, redisService, protocols[i+1])
+				return
+			}
+		}
+
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization required"})
+		c.Abort()
+	}
+}
+
+// EventStreamAuthMiddleware authenticates /api/events from the
+// Authorization header or, for the browser's native EventSource, a short-lived
+// stream ticket in ?ticket=. The ticket is bound to a login session, which
+// must still be alive.
+func EventStreamAuthMiddleware(jwtService *services.JWTService, redisService *services.RedisService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			parts := strings.SplitN(authHeader, " ", 2)
+			if len(parts) != 2 || parts[0] != "Bearer" {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid authorization format"})
 				c.Abort()
 				return
 			}
+			authenticate(c, jwtService, redisService, parts[1])
+			return
 		}
 
-		claims, err := jwtService.ValidateToken(tokenString)
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Authorization required"})
+			c.Abort()
+			return
+		}
+
+		record, err := redisService.GetStreamTicket(ticket)
+		if err == services.ErrStreamTicketInvalid {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired stream ticket"})
+			c.Abort()
+			return
+		}
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate stream ticket"})
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", claims.UserID)
-		c.Set("session_id", claims.SessionID)
+		alive, err := redisService.SessionExists(record.UserID, record.SessionID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate session"})
+			c.Abort()
+			return
+		}
+		if !alive {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", record.UserID)
+		c.Set("session_id", record.SessionID)
+		c.Set("role", record.Role)
 
 		c.Next()
 	}
 }
 
+// authenticate checks the token and that its session has not been logged
+// out or revoked, so a signed token alone is not enough.
+func authenticate(c *gin.Context, jwtService *services.JWTService, redisService *services.RedisService, tokenString string) {
+	claims, err := jwtService.ValidateToken(tokenString)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		c.Abort()
+		return
+	}
+
+	alive, err := redisService.SessionExists(claims.UserID, claims.SessionID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate session"})
+		c.Abort()
+		return
+	}
+	if !alive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired or revoked"})
+		c.Abort()
+		return
+	}
+
+	c.Set("user_id", claims.UserID)
+	c.Set("session_id", claims.SessionID)
+	c.Set("role", claims.Role)
+
+	c.Next()
+}
+
 func RateLimitMiddleware(redisService *services.RedisService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		userID, exists := c.Get("user_id")
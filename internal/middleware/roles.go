@@ -0,0 +1,47 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+// ResolveRole replaces the token's role claim with the user's current role,
+// so a demotion takes effect on the next request rather than when the token
+// expires. It must run after AuthMiddleware and before RequireRole.
+func ResolveRole(roleService *services.RoleService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, err := roleService.GetRole(c.GetInt64("user_id"))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve role"})
+			c.Abort()
+			return
+		}
+
+		c.Set("role", role)
+		c.Next()
+	}
+}
+
+// RequireRole lets a request through only if the request's role is one of
+// roles. It must run after AuthMiddleware, and after ResolveRole wherever
+// the role claim alone is not trusted.
+func RequireRole(roles ...models.Role) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role, _ := c.Get("role")
+		current, _ := role.(models.Role)
+
+		for _, allowed := range roles {
+			if current == allowed {
+				c.Next()
+				return
+			}
+		}
+
+		c.JSON(http.StatusForbidden, gin.H{"error": "Insufficient role"})
+		c.Abort()
+	}
+}
@@ -0,0 +1,123 @@
+package models
+
+import "time"
+
+type AccountStatus string
+
+const (
+	AccountActive AccountStatus = "active"
+	// Frozen and under review players can log in and look around but not
+	// move money; under review is the hold used while fraud is investigated
+	AccountFrozen      AccountStatus = "frozen"
+	AccountUnderReview AccountStatus = "under_review"
+	// Closed players cannot log in at all
+	AccountClosed AccountStatus = "closed"
+)
+
+func (s AccountStatus) CanLogin() bool {
+	return s != AccountClosed
+}
+
+// AdminHold is true for statuses only an admin may lift: a closed account
+// or a fraud review.
+func (s AccountStatus) AdminHold() bool {
+	return s == AccountClosed || s == AccountUnderReview
+}
+
+// CanMoveMoney covers bets, every in-game action, cashouts, deposits and
+// withdrawals. Games in play when an account stops being able to move money
+// are refunded rather than left to run out.
+func (s AccountStatus) CanMoveMoney() bool {
+	return s == AccountActive
+}
+
+// AccountState is a player's standing. Players without a stored state are
+// active.
+type AccountState struct {
+	UserID    int64         `json:"user_id"`
+	Status    AccountStatus `json:"status"`
+	Reason    string        `json:"reason,omitempty"`
+	ChangedBy int64         `json:"changed_by,omitempty"`
+	ChangedAt time.Time     `json:"changed_at,omitempty"`
+}
+
+type AuditAction string
+
+const (
+	AuditSetRole       AuditAction = "set_role"
+	AuditAdjustBalance AuditAction = "adjust_balance"
+	// Credits above the approval threshold are requested by one person and
+	// approved or rejected by another admin
+	AuditRequestAdjustment AuditAction = "request_balance_adjustment"
+	AuditApproveAdjustment AuditAction = "approve_balance_adjustment"
+	AuditRejectAdjustment  AuditAction = "reject_balance_adjustment"
+	AuditDeleteWallet      AuditAction = "delete_wallet"
+	AuditFreezeAccount     AuditAction = "freeze_account"
+	AuditUnfreezeAccount   AuditAction = "unfreeze_account"
+	AuditSetAccountStatus  AuditAction = "set_account_status"
+	AuditRevokeSessions    AuditAction = "revoke_sessions"
+	AuditClearRateLimits   AuditAction = "clear_rate_limits"
+	AuditForceCrash        AuditAction = "force_crash"
+	AuditRotateServerSeed  AuditAction = "rotate_server_seed"
+	AuditForceSettle       AuditAction = "force_settle"
+	AuditUpdateGameConfig  AuditAction = "update_game_config"
+	AuditCreateAPIKey      AuditAction = "create_api_key"
+	AuditRevokeAPIKey      AuditAction = "revoke_api_key"
+	AuditAPIKeyCall        AuditAction = "api_key_call"
+
+	// An approval whose credit failed; the adjustment goes back to pending
+	AuditApproveAdjustmentFailed AuditAction = "approve_balance_adjustment_failed"
+	// A game settled by the stale game sweep rather than a person
+	AuditExpireGame AuditAction = "expire_game"
+)
+
+// AuditEntry records one staff action. Entries are append-only; there is no
+// API to edit or delete them. Calls made with an API key have APIKeyID set
+// and no actor.
+type AuditEntry struct {
+	ID           string                 `json:"id,omitempty"` // stream entry ID
+	ActorID      int64                  `json:"actor_id"`
+	ActorRole    Role                   `json:"actor_role"`
+	APIKeyID     string                 `json:"api_key_id,omitempty"`
+	Action       AuditAction            `json:"action"`
+	TargetUserID int64                  `json:"target_user_id,omitempty"`
+	TargetGameID string                 `json:"target_game_id,omitempty"`
+	Reason       string                 `json:"reason,omitempty"`
+	Details      map[string]interface{} `json:"details,omitempty"`
+	IP           string                 `json:"ip,omitempty"`
+	CreatedAt    time.Time              `json:"created_at"`
+}
+
+// BalanceAdjustmentRequest credits (positive) or debits (negative) a
+// player's balance in cents.
+type BalanceAdjustmentRequest struct {
+	Amount float64 `json:"amount" binding:"required"`
+	Reason string  `json:"reason" binding:"required,min=3,max=500"`
+}
+
+// PendingAdjustment is a credit above the approval threshold, held until a
+// second admin approves it.
+type PendingAdjustment struct {
+	ID                string    `json:"id"`
+	UserID            int64     `json:"user_id"`
+	Amount            float64   `json:"amount"`
+	Reason            string    `json:"reason"`
+	RequestedBy       int64     `json:"requested_by,omitempty"`
+	RequestedByAPIKey string    `json:"requested_by_api_key,omitempty"`
+	CreatedAt         time.Time `json:"created_at"`
+}
+
+type AccountActionRequest struct {
+	Reason string `json:"reason" binding:"required,min=3,max=500"`
+}
+
+type SetAccountStatusRequest struct {
+	Status AccountStatus `json:"status" binding:"required,oneof=active frozen under_review closed"`
+	Reason string        `json:"reason" binding:"required,min=3,max=500"`
+}
+
+type BetPattern struct {
+	Amount    float64  `json:"amount"`
+	GameType  GameType `json:"game_type"`
+	Timestamp int64    `json:"timestamp"`
+}
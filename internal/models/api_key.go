@@ -0,0 +1,106 @@
+package models
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// APIScope grants an API key access to one group of service routes.
+type APIScope string
+
+const (
+	ScopePlayersRead     APIScope = "players:read"
+	ScopePlayersWrite    APIScope = "players:write" // balance, freeze, sessions, rate limits
+	ScopeGamesSettle     APIScope = "games:settle"
+	ScopeGameConfigRead  APIScope = "game_config:read"
+	ScopeGameConfigWrite APIScope = "game_config:write"
+	ScopeAuditRead       APIScope = "audit:read"
+)
+
+const DefaultAPIKeyRateLimit = 60 // requests per minute
+
+func (s APIScope) IsValid() bool {
+	switch s {
+	case ScopePlayersRead, ScopePlayersWrite, ScopeGamesSettle,
+		ScopeGameConfigRead, ScopeGameConfigWrite, ScopeAuditRead:
+		return true
+	}
+	return false
+}
+
+// APIKey lets a backend caller use the service API without a Telegram
+// user. Only the SHA-256 of the secret is stored; the secret itself is
+// shown once, when the key is created.
+type APIKey struct {
+	ID     string     `json:"id"`
+	Name   string     `json:"name"`
+	Prefix string     `json:"prefix"` // first characters of the secret, to tell keys apart
+	Scopes []APIScope `json:"scopes"`
+	// AllowedIPs holds IPs or CIDR ranges; empty allows any address
+	AllowedIPs []string  `json:"allowed_ips,omitempty"`
+	RateLimit  int       `json:"rate_limit"` // requests per minute
+	CreatedBy  int64     `json:"created_by"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  time.Time `json:"revoked_at,omitempty"`
+	Revoked    bool      `json:"revoked"`
+}
+
+func (k *APIKey) HasScope(scope APIScope) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (k *APIKey) AllowsIP(ip string) bool {
+	if len(k.AllowedIPs) == 0 {
+		return true
+	}
+
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+	for _, allowed := range k.AllowedIPs {
+		if strings.Contains(allowed, "/") {
+			if _, network, err := net.ParseCIDR(allowed); err == nil && network.Contains(addr) {
+				return true
+			}
+			continue
+		}
+		if other := net.ParseIP(allowed); other != nil && other.Equal(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+type CreateAPIKeyRequest struct {
+	Name       string     `json:"name" binding:"required,min=3,max=100"`
+	Scopes     []APIScope `json:"scopes" binding:"required,min=1"`
+	AllowedIPs []string   `json:"allowed_ips"`
+	RateLimit  int        `json:"rate_limit" binding:"min=0,max=10000"` // 0 uses the default
+}
+
+func (r *CreateAPIKeyRequest) Validate() error {
+	for _, scope := range r.Scopes {
+		if !scope.IsValid() {
+			return fmt.Errorf("unknown scope %q", scope)
+		}
+	}
+	for _, allowed := range r.AllowedIPs {
+		if strings.Contains(allowed, "/") {
+			if _, _, err := net.ParseCIDR(allowed); err != nil {
+				return fmt.Errorf("invalid CIDR %q", allowed)
+			}
+		} else if net.ParseIP(allowed) == nil {
+			return fmt.Errorf("invalid IP address %q", allowed)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package models
+
+import "time"
+
+// EventSchemaVersion is bumped when Event changes in a way consumers must
+// know about. Adding optional fields does not require a bump.
+const EventSchemaVersion = 1
+
+type EventType string
+
+const (
+	EventBetPlaced     EventType = "bet.placed"
+	EventGameTick      EventType = "game.tick" // crash multiplier passed a whole number
+	EventGameCashout   EventType = "game.cashout"
+	EventGameCrash     EventType = "game.crash"
+	EventGameReveal    EventType = "game.reveal"
+	EventGameSettled   EventType = "game.settled" // dice roll, mine hit
+	EventGameRefund    EventType = "game.refund"
+	EventBigWin        EventType = "game.big_win"
+	EventWalletChanged EventType = "wallet.changed"
+	EventDeposit       EventType = "wallet.deposit"
+	EventWithdrawal    EventType = "wallet.withdrawal"
+)
+
+// Thresholds for EventBigWin; either one is enough.
+const (
+	BigWinMultiplier = 10.0
+	BigWinPayout     = 50000 // $500.00 in cents
+)
+
+var KnownEventTypes = []EventType{
+	EventBetPlaced,
+	EventGameTick,
+	EventGameCashout,
+	EventGameCrash,
+	EventGameReveal,
+	EventGameSettled,
+	EventGameRefund,
+	EventBigWin,
+	EventWalletChanged,
+	EventDeposit,
+	EventWithdrawal,
+}
+
+func (t EventType) IsKnown() bool {
+	for _, known := range KnownEventTypes {
+		if t == known {
+			return true
+		}
+	}
+	return false
+}
+
+type Event struct {
+	ID      string    `json:"id,omitempty"` // stream entry ID, set when read back
+	Type    EventType `json:"type"`
+	Version int       `json:"version"`
+	UserID  int64     `json:"user_id"`
+
+	GameID     string     `json:"game_id,omitempty"`
+	GameType   GameType   `json:"game_type,omitempty"`
+	Status     GameStatus `json:"status,omitempty"`
+	Amount     float64    `json:"amount,omitempty"` // bet, payout or wallet delta
+	Multiplier float64    `json:"multiplier,omitempty"`
+	Balance    float64    `json:"balance,omitempty"` // wallet balance after the change
+	// WalletVersion increases with every wallet write; consumers use it to
+	// drop out-of-order balance updates.
+	WalletVersion int64  `json:"wallet_version,omitempty"`
+	Position      *int   `json:"position,omitempty"`
+	IsMine        bool   `json:"is_mine,omitempty"`
+	Reason        string `json:"reason,omitempty"`
+
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// NewGameEvent fills the envelope and game fields from a session.
+func NewGameEvent(eventType EventType, session *GameSession) *Event {
+	return &Event{
+		Type:       eventType,
+		Version:    EventSchemaVersion,
+		UserID:     session.UserID,
+		GameID:     session.ID,
+		GameType:   session.GameType,
+		Status:     session.Status,
+		Amount:     session.BetAmount,
+		Multiplier: session.Multiplier,
+		CreatedAt:  time.Now(),
+	}
+}
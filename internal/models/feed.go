@@ -0,0 +1,54 @@
+package models
+
+import (
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type FeedType string
+
+const (
+	FeedAll         FeedType = "all"
+	FeedHighRollers FeedType = "high_rollers"
+)
+
+// HighRollerBet puts a bet on the high rollers feed regardless of outcome;
+// wins that qualify as big wins are listed there too.
+const HighRollerBet = 5000 // $50.00 in cents
+
+// FeedEntry is one settled bet as shown to other players. It carries no
+// user or game IDs.
+type FeedEntry struct {
+	Player     string    `json:"player"` // masked
+	GameType   GameType  `json:"game_type"`
+	BetAmount  float64   `json:"bet_amount"`
+	Multiplier float64   `json:"multiplier"`
+	Payout     float64   `json:"payout"`
+	Win        bool      `json:"win"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func (e *FeedEntry) IsHighRoller() bool {
+	return e.BetAmount >= HighRollerBet ||
+		(e.Win && (e.Multiplier >= BigWinMultiplier || e.Payout >= BigWinPayout))
+}
+
+type FeedPrivacyRequest struct {
+	HideFromFeed bool `json:"hide_from_feed"`
+}
+
+// MaskUsername keeps the first two characters of a name, e.g. "mi***".
+func MaskUsername(name string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "Player***"
+	}
+
+	keep := 2
+	if utf8.RuneCountInString(name) <= 2 {
+		keep = 1
+	}
+	runes := []rune(name)
+	return string(runes[:keep]) + "***"
+}
@@ -26,16 +26,25 @@ type GameSession struct {
 	Nonce      int64  `json:"nonce" redis:"nonce"`
 	FinalHash  string `json:"final_hash" redis:"final_hash"`
 
-	Status    string                 `json:"status" redis:"status"` // active, cashed_out, crashed, completed
-	CreatedAt time.Time              `json:"created_at" redis:"created_at"`
-	UpdatedAt time.Time              `json:"updated_at" redis:"updated_at"`
-	EndedAt   time.Time              `json:"ended_at" redis:"ended_at"`
-	Metadata  map[string]interface{} `json:"metadata" redis:"metadata"`
+	// Game configuration in force when the bet was placed
+	HouseEdge     float64 `json:"house_edge,omitempty" redis:"house_edge"`
+	MaxMultiplier float64 `json:"max_multiplier,omitempty" redis:"max_multiplier"`
+	ConfigVersion int64   `json:"config_version,omitempty" redis:"config_version"`
+
+	// Revision counts stored writes, so a write from a stale copy is refused
+	Revision int64 `json:"revision,omitempty" redis:"revision"`
+
+	Status      GameStatus         `json:"status" redis:"status"`
+	Transitions []StatusTransition `json:"transitions,omitempty" redis:"transitions"`
+	CreatedAt   time.Time          `json:"created_at" redis:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at" redis:"updated_at"`
+	EndedAt     time.Time          `json:"ended_at" redis:"ended_at"`
+	Metadata    *GameMetadata      `json:"metadata" redis:"metadata"`
 }
 
 type BetRequest struct {
 	GameType GameType `json:"game_type" binding:"required"`
-	Amount   float64  `json:"amount" binding:"required,min=1,max=10000"`
+	Amount   float64  `json:"amount" binding:"required,min=1"` // per-game limits come from GameConfig
 }
 
 type CashoutRequest struct {
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// ConfigurableGameTypes are the game types the engine can run and that
+// operators can tune at runtime.
+var ConfigurableGameTypes = []GameType{GameTypeCrash, GameTypeMines, GameTypeDice}
+
+// GameConfig is the live tuning for one game type. The engine reads it on
+// every bet, so changes apply to the next bet without a redeploy. Bets
+// already placed keep the values they started with.
+type GameConfig struct {
+	GameType GameType `json:"game_type"`
+	// Enabled false is the kill switch: new bets are refused
+	Enabled bool `json:"enabled"`
+	// HouseEdge is a fraction (0.01 = 1%). Applies to crash and dice; the
+	// mines payout table is fixed and only capped by MaxMultiplier.
+	HouseEdge     float64 `json:"house_edge"`
+	MinBet        float64 `json:"min_bet"` // cents
+	MaxBet        float64 `json:"max_bet"` // cents
+	MaxMultiplier float64 `json:"max_multiplier"`
+
+	// Version is 0 for the built-in defaults and bumped on every change
+	Version   int64     `json:"version"`
+	UpdatedBy int64     `json:"updated_by,omitempty"`
+	UpdatedAt time.Time `json:"updated_at,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+}
+
+func DefaultGameConfig(gameType GameType) *GameConfig {
+	cfg := &GameConfig{
+		GameType:      gameType,
+		Enabled:       true,
+		HouseEdge:     0.01,
+		MinBet:        1,
+		MaxBet:        10000,
+		MaxMultiplier: 1000,
+	}
+	if gameType == GameTypeDice {
+		cfg.MaxMultiplier = 9900
+	}
+	return cfg
+}
+
+func (gc *GameConfig) Validate() error {
+	if gc.HouseEdge < 0 || gc.HouseEdge >= 0.5 {
+		return fmt.Errorf("house_edge must be between 0 and 0.5")
+	}
+	if gc.MinBet < 1 {
+		return fmt.Errorf("min_bet must be at least 1 cent")
+	}
+	if gc.MaxBet < gc.MinBet {
+		return fmt.Errorf("max_bet must not be below min_bet")
+	}
+	if gc.MaxMultiplier <= 1 {
+		return fmt.Errorf("max_multiplier must be above 1")
+	}
+	return nil
+}
+
+// CheckBet rejects a bet the current configuration does not allow.
+func (gc *GameConfig) CheckBet(amount float64) error {
+	if !gc.Enabled {
+		return fmt.Errorf("%s is temporarily disabled", gc.GameType)
+	}
+	if amount < gc.MinBet {
+		return fmt.Errorf("minimum bet for %s is %s", gc.GameType, FormatCurrency(gc.MinBet))
+	}
+	if amount > gc.MaxBet {
+		return fmt.Errorf("maximum bet for %s is %s", gc.GameType, FormatCurrency(gc.MaxBet))
+	}
+	return nil
+}
+
+// GameConfigUpdate changes only the fields that are set. ExpectedVersion,
+// when set, makes the update fail if someone else changed the config first.
+type GameConfigUpdate struct {
+	Enabled         *bool    `json:"enabled"`
+	HouseEdge       *float64 `json:"house_edge"`
+	MinBet          *float64 `json:"min_bet"`
+	MaxBet          *float64 `json:"max_bet"`
+	MaxMultiplier   *float64 `json:"max_multiplier"`
+	ExpectedVersion *int64   `json:"expected_version"`
+	Reason          string   `json:"reason" binding:"required,min=3,max=500"`
+}
+
+func (u *GameConfigUpdate) Apply(gc *GameConfig) {
+	if u.Enabled != nil {
+		gc.Enabled = *u.Enabled
+	}
+	if u.HouseEdge != nil {
+		gc.HouseEdge = *u.HouseEdge
+	}
+	if u.MinBet != nil {
+		gc.MinBet = *u.MinBet
+	}
+	if u.MaxBet != nil {
+		gc.MaxBet = *u.MaxBet
+	}
+	if u.MaxMultiplier != nil {
+		gc.MaxMultiplier = *u.MaxMultiplier
+	}
+}
+
+type GameOutcome string
+
+const (
+	OutcomeCrash  GameOutcome = "crash"  // settle as lost
+	OutcomeRefund GameOutcome = "refund" // return the stake
+)
+
+type ForceSettleRequest struct {
+	Outcome GameOutcome `json:"outcome" binding:"required,oneof=crash refund"`
+	Reason  string      `json:"reason" binding:"required,min=3,max=500"`
+}
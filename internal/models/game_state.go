@@ -0,0 +1,136 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// GameMetadataVersion is bumped whenever a state payload changes shape.
+// Sessions written before versioning existed decode as version 0.
+const GameMetadataVersion = 1
+
+// GameMetadata is the per-game state stored on a GameSession. Type says which
+// one of the state pointers is set.
+type GameMetadata struct {
+	Version int      `json:"version"`
+	Type    GameType `json:"type"`
+
+	Crash *CrashState `json:"crash,omitempty"`
+	Mines *MinesState `json:"mines,omitempty"`
+	Dice  *DiceState  `json:"dice,omitempty"`
+}
+
+type CrashState struct {
+	Ticks int64 `json:"ticks"`
+}
+
+type MinesState struct {
+	Mines       []int           `json:"mines"`
+	GridSize    int             `json:"grid_size"`
+	MineCount   int             `json:"mine_count"`
+	Revealed    []int           `json:"revealed"`
+	Multipliers map[int]float64 `json:"multipliers"`
+}
+
+type DiceState struct {
+	Roll   int     `json:"roll"`
+	Target int     `json:"target"`
+	Over   bool    `json:"over"`
+	Played bool    `json:"played"`
+	Win    bool    `json:"win"`
+	Payout float64 `json:"payout"`
+}
+
+func NewCrashMetadata() *GameMetadata {
+	return &GameMetadata{Version: GameMetadataVersion, Type: GameTypeCrash, Crash: &CrashState{}}
+}
+
+func NewMinesMetadata(state *MinesState) *GameMetadata {
+	return &GameMetadata{Version: GameMetadataVersion, Type: GameTypeMines, Mines: state}
+}
+
+func NewDiceMetadata(state *DiceState) *GameMetadata {
+	return &GameMetadata{Version: GameMetadataVersion, Type: GameTypeDice, Dice: state}
+}
+
+func (ms *MinesState) IsMine(position int) bool {
+	for _, pos := range ms.Mines {
+		if pos == position {
+			return true
+		}
+	}
+	return false
+}
+
+func (ms *MinesState) IsRevealed(position int) bool {
+	for _, pos := range ms.Revealed {
+		if pos == position {
+			return true
+		}
+	}
+	return false
+}
+
+// CurrentMultiplier is the payout multiplier for the tiles revealed so far.
+func (ms *MinesState) CurrentMultiplier() float64 {
+	return ms.Multipliers[len(ms.Revealed)]
+}
+
+// legacyDiceState is the flat map createDiceGame used to write. The bet
+// direction was stored as "is_over" until played, then as "over".
+type legacyDiceState struct {
+	Roll   int     `json:"roll"`
+	Target int     `json:"target"`
+	IsOver bool    `json:"is_over"`
+	Over   *bool   `json:"over"`
+	Played bool    `json:"played"`
+	Win    bool    `json:"win"`
+	Payout float64 `json:"payout"`
+}
+
+// UnmarshalJSON accepts both the versioned format and the untyped
+// map[string]interface{} payloads stored by older releases.
+func (m *GameMetadata) UnmarshalJSON(data []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+
+	if _, ok := raw["version"]; ok {
+		type plain GameMetadata
+		return json.Unmarshal(data, (*plain)(m))
+	}
+
+	*m = GameMetadata{}
+
+	switch {
+	case raw["mines"] != nil:
+		var state MinesState
+		if err := json.Unmarshal(data, &state); err != nil {
+			return fmt.Errorf("failed to decode legacy mines metadata: %v", err)
+		}
+		m.Type = GameTypeMines
+		m.Mines = &state
+
+	case raw["roll"] != nil:
+		var legacy legacyDiceState
+		if err := json.Unmarshal(data, &legacy); err != nil {
+			return fmt.Errorf("failed to decode legacy dice metadata: %v", err)
+		}
+		over := legacy.IsOver
+		if legacy.Over != nil {
+			over = *legacy.Over
+		}
+		m.Type = GameTypeDice
+		m.Dice = &DiceState{
+			Roll:   legacy.Roll,
+			Target: legacy.Target,
+			Over:   over,
+			Played: legacy.Played,
+			Win:    legacy.Win,
+			Payout: legacy.Payout,
+		}
+	}
+
+	return nil
+}
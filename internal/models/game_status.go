@@ -0,0 +1,106 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+type GameStatus string
+
+const (
+	GameStatusActive    GameStatus = "active"
+	GameStatusCashedOut GameStatus = "cashed_out" // crash / mines player took the multiplier
+	GameStatusWon       GameStatus = "won"        // single-shot games (dice) settled as a win
+	GameStatusLost      GameStatus = "lost"       // mine hit or losing dice roll
+	GameStatusCrashed   GameStatus = "crashed"    // crash round ended before a cashout
+	GameStatusRefunded  GameStatus = "refunded"   // bet returned without a result
+
+	// GameStatusCompleted is how dice games were stored before wins and
+	// losses were split. It is only ever read, never written.
+	GameStatusCompleted GameStatus = "completed"
+)
+
+var ErrInvalidTransition = errors.New("invalid game status transition")
+
+// gameStatusTransitions lists every allowed move. Terminal statuses have no
+// entry, so once a game is settled nothing can re-open or re-settle it.
+var gameStatusTransitions = map[GameStatus][]GameStatus{
+	GameStatusActive: {
+		GameStatusCashedOut,
+		GameStatusWon,
+		GameStatusLost,
+		GameStatusCrashed,
+		GameStatusRefunded,
+	},
+}
+
+type StatusTransition struct {
+	From GameStatus `json:"from"`
+	To   GameStatus `json:"to"`
+	At   time.Time  `json:"at"`
+}
+
+func (s GameStatus) CanTransitionTo(to GameStatus) bool {
+	for _, allowed := range gameStatusTransitions[s] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s GameStatus) IsTerminal() bool {
+	return len(gameStatusTransitions[s]) == 0
+}
+
+func (s GameStatus) IsWin() bool {
+	return s == GameStatusCashedOut || s == GameStatusWon
+}
+
+// TransitionTo validates and applies a status change, recording it in the
+// session's transition log.
+func (gs *GameSession) TransitionTo(to GameStatus, at time.Time) error {
+	if !gs.Status.CanTransitionTo(to) {
+		return fmt.Errorf("%w: %s -> %s", ErrInvalidTransition, gs.Status, to)
+	}
+
+	gs.Transitions = append(gs.Transitions, StatusTransition{
+		From: gs.Status,
+		To:   to,
+		At:   at,
+	})
+	gs.Status = to
+	gs.UpdatedAt = at
+	if to.IsTerminal() {
+		gs.EndedAt = at
+	}
+
+	return nil
+}
+
+// Result reports the outcome of a settled game as "win", "lose" or "refund".
+func (gs *GameSession) Result() string {
+	switch {
+	case gs.Status.IsWin():
+		return "win"
+	case gs.Status == GameStatusRefunded:
+		return "refund"
+	case gs.Status == GameStatusCompleted:
+		if gs.Metadata != nil && gs.Metadata.Dice != nil && gs.Metadata.Dice.Win {
+			return "win"
+		}
+	}
+	return "lose"
+}
+
+// Payout is the amount credited back to the player when the game settled.
+func (gs *GameSession) Payout() float64 {
+	switch gs.Result() {
+	case "win":
+		return CalculatePayout(gs.BetAmount, gs.CashoutAt)
+	case "refund":
+		return gs.BetAmount
+	}
+	return 0
+}
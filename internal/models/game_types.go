@@ -12,12 +12,23 @@ type MinesRevealRequest struct {
 }
 
 type MinesRevealResponse struct {
-	GameID     string  `json:"game_id"`
-	IsMine     bool    `json:"is_mine"`
-	Multiplier float64 `json:"multiplier"`
-	Positions  []int   `json:"positions"`
-	GameOver   bool    `json:"game_over"`
-	Winnings   float64 `json:"winnings,omitempty"`
+	GameID        string     `json:"game_id"`
+	Position      int        `json:"position"`
+	IsMine        bool       `json:"is_mine"`
+	Multiplier    float64    `json:"multiplier"`
+	Positions     []int      `json:"positions"` // revealed so far, including this one
+	MinePositions []int      `json:"mine_positions,omitempty"`
+	MineCount     int        `json:"mine_count"`
+	GameOver      bool       `json:"game_over"`
+	Status        GameStatus `json:"status"`
+	Winnings      float64    `json:"winnings,omitempty"`
+}
+
+type MinesCashoutResponse struct {
+	GameResult
+	BetAmount     float64    `json:"bet_amount"`
+	RevealedCount int        `json:"revealed_count"`
+	Status        GameStatus `json:"status"`
 }
 
 type DicePlayRequest struct {
@@ -34,9 +34,6 @@ func (br *BetRequest) Validate() error {
 	if br.Amount < 1 {
 		return fmt.Errorf("bet amount must be at least 1 cent")
 	}
-	if br.Amount > 10000 {
-		return fmt.Errorf("maximum bet amount is 10000 cents ($100)")
-	}
 
 	switch br.GameType {
 	case GameTypeCrash, GameTypeMines, GameTypeDice, GameTypeAviator:
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// SigningKey is an asymmetric JWT signing key as stored and shared between
+// instances. EncryptedKey is the PKCS#8 PEM sealed with AES-256-GCM.
+// PrivateKey holds the plain PEM of keys saved before encryption, until
+// they are re-saved sealed.
+type SigningKey struct {
+	ID           string    `json:"kid"`
+	Algorithm    string    `json:"alg"`
+	EncryptedKey string    `json:"encrypted_key,omitempty"`
+	PrivateKey   string    `json:"private_key,omitempty"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// JWK is a public verification key as published in the JWKS document.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+
+	// RSA
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+
+	// Ed25519
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
@@ -20,19 +20,46 @@ type UserSession struct {
 	TelegramUser
 	SessionID    string    `json:"session_id"`
 	InitDataHash string    `json:"init_data_hash"`
+	UserAgent    string    `json:"user_agent,omitempty"`
+	IP           string    `json:"ip,omitempty"`
 	CreatedAt    time.Time `json:"created_at"`
 	LastAccessed time.Time `json:"last_accessed"`
 }
 
 type AuthResponse struct {
-	Token   string        `json:"token"`
-	Expires int64         `json:"expires"`
-	User    *TelegramUser `json:"user"`
+	Token          string        `json:"token"`
+	Expires        int64         `json:"expires"`
+	RefreshToken   string        `json:"refresh_token,omitempty"`
+	RefreshExpires int64         `json:"refresh_expires,omitempty"`
+	User           *TelegramUser `json:"user,omitempty"`
+}
+
+// RefreshToken is the stored side of an opaque refresh token. Its family is
+// the login session it belongs to.
+type RefreshToken struct {
+	UserID    int64     `json:"user_id"`
+	SessionID string    `json:"session_id"`
+	Used      bool      `json:"used"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// StreamTicket lets a browser EventSource, which cannot send headers, open
+// /api/events for a login session.
+type StreamTicket struct {
+	UserID    int64  `json:"user_id"`
+	SessionID string `json:"session_id"`
+	Role      Role   `json:"role,omitempty"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
 }
 
 type InitData struct {
-	QueryID  string `form:"query_id"`
-	User     string `form:"user"`
-	AuthDate string `form:"auth_date"`
-	Hash     string `form:"hash"`
+	QueryID   string `form:"query_id"`
+	User      string `form:"user"`
+	AuthDate  string `form:"auth_date"`
+	Hash      string `form:"hash"`
+	Signature string `form:"signature"`
 }
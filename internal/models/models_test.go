@@ -1,8 +1,12 @@
 package models_test
 
 import (
+	"encoding/json"
+	"errors"
 	"sample-miniapp-backend/internal/models"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestModels(t *testing.T) {
@@ -50,3 +54,240 @@ func TestModels(t *testing.T) {
 		t.Error("Wallet should have a client seed")
 	}
 }
+
+func TestGameStatusTransitions(t *testing.T) {
+	session := &models.GameSession{
+		ID:     models.GenerateGameID(),
+		Status: models.GameStatusActive,
+	}
+
+	if err := session.TransitionTo(models.GameStatusCrashed, time.Now()); err != nil {
+		t.Fatalf("active -> crashed should be allowed: %v", err)
+	}
+
+	if session.EndedAt.IsZero() {
+		t.Error("Terminal transition should set EndedAt")
+	}
+
+	if len(session.Transitions) != 1 || session.Transitions[0].From != models.GameStatusActive {
+		t.Errorf("Expected one recorded transition from active, got %+v", session.Transitions)
+	}
+
+	err := session.TransitionTo(models.GameStatusCashedOut, time.Now())
+	if !errors.Is(err, models.ErrInvalidTransition) {
+		t.Errorf("Cashout after crash should be rejected, got %v", err)
+	}
+
+	if session.Status != models.GameStatusCrashed {
+		t.Errorf("Rejected transition should not change status, got %s", session.Status)
+	}
+
+	dice := &models.GameSession{
+		BetAmount: 100,
+		CashoutAt: 1.98,
+		Status:    models.GameStatusWon,
+	}
+	if dice.Result() != "win" || dice.Payout() != 198 {
+		t.Errorf("Dice win should pay out, got %s / %.2f", dice.Result(), dice.Payout())
+	}
+
+	legacy := &models.GameSession{
+		BetAmount: 100,
+		CashoutAt: 1.98,
+		Status:    models.GameStatusCompleted,
+		Metadata:  models.NewDiceMetadata(&models.DiceState{Played: true, Win: true}),
+	}
+	if legacy.Result() != "win" {
+		t.Errorf("Legacy completed dice win should count as win, got %s", legacy.Result())
+	}
+}
+
+func TestGameMetadataLegacyFormat(t *testing.T) {
+	minesJSON := `{"id":"g1","game_type":"mines","status":"active","metadata":` +
+		`{"mines":[3,7,19],"grid_size":25,"mine_count":3,"revealed":[1,2],"multipliers":{"0":1,"1":1.12,"2":1.3}}}`
+
+	var mines models.GameSession
+	if err := json.Unmarshal([]byte(minesJSON), &mines); err != nil {
+		t.Fatalf("Failed to decode legacy mines session: %v", err)
+	}
+
+	if mines.Metadata == nil || mines.Metadata.Mines == nil {
+		t.Fatal("Legacy mines metadata should decode into MinesState")
+	}
+	if !mines.Metadata.Mines.IsMine(7) || mines.Metadata.Mines.CurrentMultiplier() != 1.3 {
+		t.Errorf("Unexpected mines state: %+v", mines.Metadata.Mines)
+	}
+
+	diceJSON := `{"id":"g2","game_type":"dice","status":"completed","bet_amount":100,"cashout_at":2,` +
+		`"metadata":{"roll":12,"target":60,"is_over":false,"played":true,"over":true,"win":false,"payout":0}}`
+
+	var dice models.GameSession
+	if err := json.Unmarshal([]byte(diceJSON), &dice); err != nil {
+		t.Fatalf("Failed to decode legacy dice session: %v", err)
+	}
+
+	state := dice.Metadata.Dice
+	if state == nil || state.Roll != 12 || !state.Over || state.Win {
+		t.Errorf("Unexpected dice state: %+v", state)
+	}
+	if dice.Result() != "lose" {
+		t.Errorf("Legacy losing dice game should be a loss, got %s", dice.Result())
+	}
+
+	data, err := json.Marshal(&dice)
+	if err != nil {
+		t.Fatalf("Failed to re-encode session: %v", err)
+	}
+
+	var roundTrip models.GameSession
+	if err := json.Unmarshal(data, &roundTrip); err != nil {
+		t.Fatalf("Failed to decode re-encoded session: %v", err)
+	}
+	if roundTrip.Metadata.Type != models.GameTypeDice || roundTrip.Metadata.Dice.Target != 60 {
+		t.Errorf("Round trip lost dice state: %+v", roundTrip.Metadata)
+	}
+}
+
+func TestFeedEntry(t *testing.T) {
+	masks := map[string]string{
+		"mikias": "mi***",
+		"a":      "a***",
+		"":       "Player***",
+		"Ёжик":   "Ёж***",
+	}
+	for name, want := range masks {
+		if got := models.MaskUsername(name); got != want {
+			t.Errorf("MaskUsername(%q) = %q, want %q", name, got, want)
+		}
+	}
+
+	small := &models.FeedEntry{BetAmount: 100, Payout: 150, Multiplier: 1.5, Win: true}
+	if small.IsHighRoller() {
+		t.Error("Small win should not be on the high rollers feed")
+	}
+
+	bigBet := &models.FeedEntry{BetAmount: models.HighRollerBet}
+	if !bigBet.IsHighRoller() {
+		t.Error("Large bet should be on the high rollers feed even when lost")
+	}
+
+	bigWin := &models.FeedEntry{BetAmount: 100, Payout: 1500, Multiplier: 15, Win: true}
+	if !bigWin.IsHighRoller() {
+		t.Error("Big multiplier win should be on the high rollers feed")
+	}
+}
+
+func TestRoles(t *testing.T) {
+	for _, role := range []models.Role{models.RolePlayer, models.RoleSupport, models.RoleAdmin, models.RoleAuditor} {
+		if !role.IsValid() {
+			t.Errorf("Expected %s to be valid", role)
+		}
+	}
+	if models.Role("root").IsValid() {
+		t.Error("Unknown role should be invalid")
+	}
+}
+
+func TestGameConfig(t *testing.T) {
+	cfg := models.DefaultGameConfig(models.GameTypeCrash)
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Default config should be valid: %v", err)
+	}
+	if err := cfg.CheckBet(50); err != nil {
+		t.Errorf("Bet within limits rejected: %v", err)
+	}
+	if err := cfg.CheckBet(cfg.MaxBet + 1); err == nil {
+		t.Error("Bet above max_bet should be rejected")
+	}
+
+	disabled := false
+	maxBet := 20000.0
+	update := &models.GameConfigUpdate{Enabled: &disabled, MaxBet: &maxBet}
+	update.Apply(cfg)
+	if cfg.MaxBet != 20000 || cfg.HouseEdge != 0.01 {
+		t.Errorf("Update should only change the fields it sets, got %+v", cfg)
+	}
+	if err := cfg.CheckBet(50); err == nil {
+		t.Error("Bet on a disabled game should be rejected")
+	}
+
+	edge := 0.6
+	(&models.GameConfigUpdate{HouseEdge: &edge}).Apply(cfg)
+	if err := cfg.Validate(); err == nil {
+		t.Error("House edge of 60% should be invalid")
+	}
+}
+
+func TestAPIKey(t *testing.T) {
+	key := &models.APIKey{
+		Scopes:     []models.APIScope{models.ScopePlayersRead},
+		AllowedIPs: []string{"10.0.0.0/8", "203.0.113.7"},
+	}
+
+	if !key.HasScope(models.ScopePlayersRead) || key.HasScope(models.ScopePlayersWrite) {
+		t.Error("Scope check mismatch")
+	}
+	for ip, want := range map[string]bool{
+		"10.1.2.3":     true,
+		"203.0.113.7":  true,
+		"203.0.113.8":  false,
+		"not-an-ip":    false,
+		"192.168.0.10": false,
+	} {
+		if got := key.AllowsIP(ip); got != want {
+			t.Errorf("AllowsIP(%s) = %v, want %v", ip, got, want)
+		}
+	}
+	if !(&models.APIKey{}).AllowsIP("192.168.0.10") {
+		t.Error("A key without an allowlist should allow any address")
+	}
+
+	req := &models.CreateAPIKeyRequest{Name: "bot", Scopes: []models.APIScope{"root"}}
+	if err := req.Validate(); err == nil {
+		t.Error("Unknown scope should be rejected")
+	}
+	req = &models.CreateAPIKeyRequest{Name: "bot", Scopes: []models.APIScope{models.ScopeAuditRead}, AllowedIPs: []string{"10.0.0.0/33"}}
+	if err := req.Validate(); err == nil {
+		t.Error("Invalid CIDR should be rejected")
+	}
+}
+
+func TestAccountStatus(t *testing.T) {
+	cases := []struct {
+		status       models.AccountStatus
+		canLogin     bool
+		canMoveMoney bool
+		adminHold    bool
+	}{
+		{models.AccountActive, true, true, false},
+		{models.AccountFrozen, true, false, false},
+		{models.AccountUnderReview, true, false, true},
+		{models.AccountClosed, false, false, true},
+	}
+	for _, tc := range cases {
+		if tc.status.CanLogin() != tc.canLogin {
+			t.Errorf("%s: CanLogin = %v, want %v", tc.status, !tc.canLogin, tc.canLogin)
+		}
+		if tc.status.CanMoveMoney() != tc.canMoveMoney {
+			t.Errorf("%s: CanMoveMoney = %v, want %v", tc.status, !tc.canMoveMoney, tc.canMoveMoney)
+		}
+		if tc.status.AdminHold() != tc.adminHold {
+			t.Errorf("%s: AdminHold = %v, want %v", tc.status, !tc.adminHold, tc.adminHold)
+		}
+	}
+}
+
+func TestWebhookSubscriptionRedacted(t *testing.T) {
+	sub := &models.WebhookSubscription{ID: "wh", Secret: "s3cret"}
+
+	data, err := json.Marshal(sub.Redacted())
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if strings.Contains(string(data), "s3cret") || strings.Contains(string(data), `"secret"`) {
+		t.Errorf("Redacted subscription leaks its secret: %s", data)
+	}
+	if sub.Secret != "s3cret" {
+		t.Error("Redacted should not modify the original")
+	}
+}
@@ -0,0 +1,33 @@
+package models
+
+type Role string
+
+const (
+	RolePlayer  Role = "player"
+	RoleSupport Role = "support" // player management, no game or wallet control
+	RoleAdmin   Role = "admin"   // everything
+	RoleAuditor Role = "auditor" // read-only access to the admin API
+)
+
+func (r Role) IsValid() bool {
+	switch r {
+	case RolePlayer, RoleSupport, RoleAdmin, RoleAuditor:
+		return true
+	}
+	return false
+}
+
+type RoleAssignment struct {
+	UserID int64 `json:"user_id"`
+	Role   Role  `json:"role"`
+	// Static roles come from configuration and cannot be changed via the API
+	Static bool `json:"static"`
+}
+
+type SetRoleRequest struct {
+	Role Role `json:"role" binding:"required"`
+}
+
+type RotateSeedRequest struct {
+	ServerSeed string `json:"server_seed"` // optional; generated when empty
+}
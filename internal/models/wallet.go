@@ -13,6 +13,9 @@ type Wallet struct {
 	ClientSeed string `json:"client_seed" redis:"client_seed"`
 	ServerHash string `json:"server_hash" redis:"server_hash"`
 	Nonce      int64  `json:"nonce" redis:"nonce"`
+
+	// Version is bumped on every write so clients can discard stale pushes
+	Version int64 `json:"version" redis:"version"`
 }
 
 type TransactionType string
@@ -23,6 +26,10 @@ const (
 	TransactionTypeDeposit  TransactionType = "deposit"
 	TransactionTypeWithdraw TransactionType = "withdraw"
 	TransactionTypeBonus    TransactionType = "bonus"
+	// TransactionTypeAdjustment is a manual correction by staff
+	TransactionTypeAdjustment TransactionType = "adjustment"
+	// TransactionTypeRefund returns the stake of a game that never settled
+	TransactionTypeRefund TransactionType = "refund"
 )
 
 type Transaction struct {
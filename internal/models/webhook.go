@@ -0,0 +1,64 @@
+package models
+
+import "time"
+
+// WebhookAllEvents subscribes to every event type.
+const WebhookAllEvents EventType = "*"
+
+type WebhookSubscription struct {
+	ID         string      `json:"id"`
+	URL        string      `json:"url"`
+	Secret     string      `json:"secret,omitempty"`
+	EventTypes []EventType `json:"event_types"`
+	Active     bool        `json:"active"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// Redacted returns a copy without the signing secret. The secret is shown
+// once, in the create response.
+func (ws *WebhookSubscription) Redacted() *WebhookSubscription {
+	redacted := *ws
+	redacted.Secret = ""
+	return &redacted
+}
+
+func (ws *WebhookSubscription) Matches(eventType EventType) bool {
+	if !ws.Active {
+		return false
+	}
+	for _, t := range ws.EventTypes {
+		if t == WebhookAllEvents || t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliveryDelivered WebhookDeliveryStatus = "delivered"
+	WebhookDeliveryDead      WebhookDeliveryStatus = "dead" // gave up, parked in the DLQ
+)
+
+type WebhookDelivery struct {
+	ID             string                `json:"id"`
+	SubscriptionID string                `json:"subscription_id"`
+	EventID        string                `json:"event_id"`
+	EventType      EventType             `json:"event_type"`
+	Payload        string                `json:"payload"` // exact bytes sent, so replays match the signature
+	Status         WebhookDeliveryStatus `json:"status"`
+	Attempts       int                   `json:"attempts"`
+	LastError      string                `json:"last_error,omitempty"`
+	ResponseCode   int                   `json:"response_code,omitempty"`
+	NextAttemptAt  time.Time             `json:"next_attempt_at,omitempty"`
+	CreatedAt      time.Time             `json:"created_at"`
+	UpdatedAt      time.Time             `json:"updated_at"`
+}
+
+type CreateWebhookRequest struct {
+	URL        string      `json:"url" binding:"required,url"`
+	EventTypes []EventType `json:"event_types" binding:"required,min=1"`
+	Secret     string      `json:"secret"`
+}
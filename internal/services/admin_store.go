@@ -0,0 +1,288 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"sample-miniapp-backend/internal/models"
+)
+
+var (
+	ErrAccountRestricted  = errors.New("account is restricted")
+	ErrAdjustmentNotFound = errors.New("pending adjustment not found or expired")
+)
+
+// WriteAuditEntry appends to the global audit stream and to the target
+// player's stream in one transaction. Neither stream is trimmed.
+func (s *RedisService) WriteAuditEntry(entry *models.AuditEntry) error {
+	if entry.CreatedAt.IsZero() {
+		entry.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal audit entry: %v", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	add := pipe.XAdd(s.ctx, &redis.XAddArgs{
+		Stream: KeyAdminAudit,
+		Values: map[string]interface{}{"entry": data},
+	})
+	if entry.TargetUserID != 0 {
+		pipe.XAdd(s.ctx, &redis.XAddArgs{
+			Stream: fmt.Sprintf(KeyAdminAuditUser, entry.TargetUserID),
+			Values: map[string]interface{}{"entry": data},
+		})
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return fmt.Errorf("failed to write audit entry: %v", err)
+	}
+
+	entry.ID = add.Val()
+	return nil
+}
+
+// ReadAuditLog returns the newest entries first, for one player when
+// userID is set. before pages backwards from a previous entry ID.
+func (s *RedisService) ReadAuditLog(userID int64, before string, limit int64) ([]*models.AuditEntry, error) {
+	stream := KeyAdminAudit
+	if userID != 0 {
+		stream = fmt.Sprintf(KeyAdminAuditUser, userID)
+	}
+
+	end := "+"
+	if before != "" {
+		end = "(" + before
+	}
+
+	messages, err := s.client.XRevRangeN(s.ctx, stream, end, "-", limit).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %v", err)
+	}
+
+	entries := make([]*models.AuditEntry, 0, len(messages))
+	for _, msg := range messages {
+		raw, ok := msg.Values["entry"].(string)
+		if !ok {
+			continue
+		}
+
+		var entry models.AuditEntry
+		if err := json.Unmarshal([]byte(raw), &entry); err != nil {
+			continue
+		}
+		entry.ID = msg.ID
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+func (s *RedisService) GetAccountState(userID int64) (*models.AccountState, error) {
+	data, err := s.client.Get(s.ctx, fmt.Sprintf(KeyAccountState, userID)).Result()
+	if err == redis.Nil {
+		return &models.AccountState{UserID: userID, Status: models.AccountActive}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get account state: %v", err)
+	}
+
+	var state models.AccountState
+	if err := json.Unmarshal([]byte(data), &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal account state: %v", err)
+	}
+	return &state, nil
+}
+
+func (s *RedisService) SetAccountState(state *models.AccountState) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("failed to marshal account state: %v", err)
+	}
+	if err := s.client.Set(s.ctx, fmt.Sprintf(KeyAccountState, state.UserID), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save account state: %v", err)
+	}
+	return nil
+}
+
+// CheckCanMoveMoney fails with ErrAccountRestricted unless the player's
+// account is active.
+func (s *RedisService) CheckCanMoveMoney(userID int64) error {
+	state, err := s.GetAccountState(userID)
+	if err != nil {
+		return err
+	}
+	if !state.Status.CanMoveMoney() {
+		return fmt.Errorf("%w: %s", ErrAccountRestricted, state.Status)
+	}
+	return nil
+}
+
+// AdjustWalletBalance applies a manual credit or debit and records it as an
+// adjustment transaction carrying the reason.
+func (s *RedisService) AdjustWalletBalance(userID int64, amount float64, reason string) (*models.Wallet, error) {
+	// GetWallet creates the wallet if the player never had one
+	if _, err := s.GetWallet(userID); err != nil {
+		return nil, err
+	}
+
+	var before float64
+	wallet, err := s.mutateWallet(userID, func(wallet *models.Wallet) (float64, string, error) {
+		// Stakes locked in play are not the player's to debit
+		if wallet.Balance-wallet.LockedBalance+amount < 0 {
+			return 0, "", fmt.Errorf("adjustment would take the balance below the %.2f locked in play", wallet.LockedBalance)
+		}
+		before = wallet.Balance
+		wallet.Balance += amount
+		return amount, "admin_adjustment", nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	tx := &models.Transaction{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Type:          models.TransactionTypeAdjustment,
+		Amount:        amount,
+		BalanceBefore: before,
+		BalanceAfter:  wallet.Balance,
+		Description:   reason,
+		CreatedAt:     time.Now(),
+	}
+	if err := s.SaveTransaction(tx); err != nil {
+		return wallet, err
+	}
+
+	return wallet, nil
+}
+
+// RequestAdjustment holds a large credit for a second admin's approval.
+func (s *RedisService) RequestAdjustment(adjustment *models.PendingAdjustment) error {
+	adjustment.ID = uuid.New().String()
+	adjustment.CreatedAt = time.Now()
+
+	data, err := json.Marshal(adjustment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal adjustment: %v", err)
+	}
+	if err := s.client.HSet(s.ctx, KeyPendingAdjustments, adjustment.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save adjustment: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisService) GetPendingAdjustment(id string) (*models.PendingAdjustment, error) {
+	data, err := s.client.HGet(s.ctx, KeyPendingAdjustments, id).Result()
+	if err == redis.Nil {
+		return nil, ErrAdjustmentNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get adjustment: %v", err)
+	}
+
+	var adjustment models.PendingAdjustment
+	if err := json.Unmarshal([]byte(data), &adjustment); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal adjustment: %v", err)
+	}
+	if time.Since(adjustment.CreatedAt) > TTLPendingAdjust {
+		s.client.HDel(s.ctx, KeyPendingAdjustments, id)
+		return nil, ErrAdjustmentNotFound
+	}
+	return &adjustment, nil
+}
+
+// ListPendingAdjustments returns the adjustments awaiting approval, oldest
+// first, and drops lapsed ones.
+func (s *RedisService) ListPendingAdjustments() ([]*models.PendingAdjustment, error) {
+	records, err := s.client.HGetAll(s.ctx, KeyPendingAdjustments).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list adjustments: %v", err)
+	}
+
+	adjustments := make([]*models.PendingAdjustment, 0, len(records))
+	for id, data := range records {
+		var adjustment models.PendingAdjustment
+		if err := json.Unmarshal([]byte(data), &adjustment); err != nil {
+			continue
+		}
+		if time.Since(adjustment.CreatedAt) > TTLPendingAdjust {
+			s.client.HDel(s.ctx, KeyPendingAdjustments, id)
+			continue
+		}
+		adjustments = append(adjustments, &adjustment)
+	}
+
+	sort.Slice(adjustments, func(i, j int) bool {
+		return adjustments[i].CreatedAt.Before(adjustments[j].CreatedAt)
+	})
+	return adjustments, nil
+}
+
+// ClaimPendingAdjustment removes an adjustment so it is approved or
+// rejected exactly once. Only the caller that removed it gets true.
+func (s *RedisService) ClaimPendingAdjustment(id string) (bool, error) {
+	removed, err := s.client.HDel(s.ctx, KeyPendingAdjustments, id).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim adjustment: %v", err)
+	}
+	return removed == 1, nil
+}
+
+// RestorePendingAdjustment puts back a claimed adjustment whose credit
+// failed, so it can be approved again. It does not overwrite one that is
+// somehow pending under the same ID.
+func (s *RedisService) RestorePendingAdjustment(adjustment *models.PendingAdjustment) error {
+	data, err := json.Marshal(adjustment)
+	if err != nil {
+		return fmt.Errorf("failed to marshal adjustment: %v", err)
+	}
+	if err := s.client.HSetNX(s.ctx, KeyPendingAdjustments, adjustment.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to restore adjustment: %v", err)
+	}
+	return nil
+}
+
+// ClearUserRateLimits drops every rate limit counter for a player and
+// returns how many there were.
+func (s *RedisService) ClearUserRateLimits(userID int64) (int, error) {
+	var keys []string
+	iter := s.client.Scan(s.ctx, 0, fmt.Sprintf(KeyRateLimit, userID, "*"), 100).Iterator()
+	for iter.Next(s.ctx) {
+		keys = append(keys, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return 0, fmt.Errorf("failed to find rate limits: %v", err)
+	}
+
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	if err := s.client.Del(s.ctx, keys...).Err(); err != nil {
+		return 0, fmt.Errorf("failed to clear rate limits: %v", err)
+	}
+	return len(keys), nil
+}
+
+func (s *RedisService) GetBetPatterns(userID int64) ([]*models.BetPattern, error) {
+	values, err := s.client.LRange(s.ctx, fmt.Sprintf(KeyBetPatterns, userID), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bet patterns: %v", err)
+	}
+
+	patterns := make([]*models.BetPattern, 0, len(values))
+	for _, data := range values {
+		var pattern models.BetPattern
+		if err := json.Unmarshal([]byte(data), &pattern); err != nil {
+			continue
+		}
+		patterns = append(patterns, &pattern)
+	}
+	return patterns, nil
+}
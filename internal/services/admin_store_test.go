@@ -0,0 +1,163 @@
+package services_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestAdminPlayerActions(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	userID := time.Now().UnixNano() % 1000000000
+	defer redisService.DeleteWallet(userID)
+
+	wallet, err := redisService.GetWallet(userID)
+	if err != nil {
+		t.Fatalf("Failed to get wallet: %v", err)
+	}
+	start := wallet.Balance
+
+	wallet, err = redisService.AdjustWalletBalance(userID, 250, "goodwill credit")
+	if err != nil {
+		t.Fatalf("Failed to adjust balance: %v", err)
+	}
+	if wallet.Balance != start+250 {
+		t.Errorf("Expected balance %.0f, got %.0f", start+250, wallet.Balance)
+	}
+	if _, err := redisService.AdjustWalletBalance(userID, -(start + 1000), "too much"); err == nil {
+		t.Error("Expected a debit below zero to fail")
+	}
+
+	txs, err := redisService.GetUserTransactions(userID, 10)
+	if err != nil || len(txs) != 1 || txs[0].Type != models.TransactionTypeAdjustment {
+		t.Errorf("Expected one adjustment transaction, got %v (%v)", txs, err)
+	}
+	if err := redisService.CheckCanMoveMoney(userID); err != nil {
+		t.Errorf("Expected an active account to move money, got %v", err)
+	}
+
+	state, err := redisService.GetAccountState(userID)
+	if err != nil || state.Status != models.AccountActive {
+		t.Errorf("Expected a new account to be active, got %+v (%v)", state, err)
+	}
+	if err := redisService.SetAccountState(&models.AccountState{UserID: userID, Status: models.AccountFrozen, Reason: "test"}); err != nil {
+		t.Fatalf("Failed to freeze account: %v", err)
+	}
+	state, _ = redisService.GetAccountState(userID)
+	if state.Status != models.AccountFrozen {
+		t.Errorf("Expected frozen, got %s", state.Status)
+	}
+	if err := redisService.CheckCanMoveMoney(userID); !errors.Is(err, services.ErrAccountRestricted) {
+		t.Errorf("Expected a frozen account to be restricted, got %v", err)
+	}
+	if err := redisService.UpdateWalletBalance(userID, 100); !errors.Is(err, services.ErrAccountRestricted) {
+		t.Errorf("Expected a deposit to a frozen account to fail, got %v", err)
+	}
+	if _, err := redisService.AdjustWalletBalance(userID, 100, "staff credit"); err != nil {
+		t.Errorf("Expected staff adjustments to work on a frozen account, got %v", err)
+	}
+
+	for _, action := range []models.AuditAction{models.AuditAdjustBalance, models.AuditFreezeAccount} {
+		if err := redisService.WriteAuditEntry(&models.AuditEntry{
+			ActorID:      1,
+			ActorRole:    models.RoleSupport,
+			Action:       action,
+			TargetUserID: userID,
+		}); err != nil {
+			t.Fatalf("Failed to write audit entry: %v", err)
+		}
+	}
+
+	entries, err := redisService.ReadAuditLog(userID, "", 10)
+	if err != nil {
+		t.Fatalf("Failed to read audit log: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Action != models.AuditFreezeAccount {
+		t.Fatalf("Expected newest entry first, got %+v", entries)
+	}
+
+	older, err := redisService.ReadAuditLog(userID, entries[0].ID, 10)
+	if err != nil || len(older) != 1 || older[0].Action != models.AuditAdjustBalance {
+		t.Errorf("Expected paging to return the older entry, got %+v (%v)", older, err)
+	}
+}
+
+func TestAdjustmentGuards(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	userID := time.Now().UnixNano() % 1000000000
+	defer redisService.DeleteWallet(userID)
+
+	if err := redisService.LockBalanceForGame(userID, 100); err != nil {
+		t.Fatalf("Failed to lock balance: %v", err)
+	}
+	wallet, err := redisService.GetWallet(userID)
+	if err != nil {
+		t.Fatalf("Failed to get wallet: %v", err)
+	}
+	free := wallet.Balance - wallet.LockedBalance
+
+	// The stake in play is not there to debit
+	if _, err := redisService.AdjustWalletBalance(userID, -(free + 1), "too much"); err == nil {
+		t.Error("Expected a debit into the locked balance to fail")
+	}
+	if _, err := redisService.AdjustWalletBalance(userID, -free, "all but the stake"); err != nil {
+		t.Errorf("Expected a debit of the free balance to work, got %v", err)
+	}
+
+	adjustment := &models.PendingAdjustment{UserID: userID, Amount: 1000000, Reason: "large credit", RequestedBy: 1}
+	if err := redisService.RequestAdjustment(adjustment); err != nil {
+		t.Fatalf("Failed to request adjustment: %v", err)
+	}
+	defer redisService.ClaimPendingAdjustment(adjustment.ID)
+
+	stored, err := redisService.GetPendingAdjustment(adjustment.ID)
+	if err != nil || stored.Amount != adjustment.Amount || stored.RequestedBy != 1 {
+		t.Errorf("Expected the pending adjustment back, got %+v (%v)", stored, err)
+	}
+
+	claimed, err := redisService.ClaimPendingAdjustment(adjustment.ID)
+	if err != nil || !claimed {
+		t.Fatalf("Expected the first claim to succeed, got %v (%v)", claimed, err)
+	}
+	if claimed, _ := redisService.ClaimPendingAdjustment(adjustment.ID); claimed {
+		t.Error("Expected a second claim to fail")
+	}
+	if _, err := redisService.GetPendingAdjustment(adjustment.ID); !errors.Is(err, services.ErrAdjustmentNotFound) {
+		t.Errorf("Expected a claimed adjustment to be gone, got %v", err)
+	}
+
+	// A failed approval puts the adjustment back as it was
+	if err := redisService.RestorePendingAdjustment(adjustment); err != nil {
+		t.Fatalf("Failed to restore adjustment: %v", err)
+	}
+	restored, err := redisService.GetPendingAdjustment(adjustment.ID)
+	if err != nil || restored.Amount != adjustment.Amount || !restored.CreatedAt.Equal(adjustment.CreatedAt) {
+		t.Errorf("Expected the restored adjustment back unchanged, got %+v (%v)", restored, err)
+	}
+}
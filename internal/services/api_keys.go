@@ -0,0 +1,200 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+
+	"sample-miniapp-backend/internal/models"
+)
+
+var (
+	ErrAPIKeyInvalid  = errors.New("API key is invalid or revoked")
+	ErrAPIKeyNotFound = errors.New("API key not found")
+)
+
+// apiKeyPrefix marks our secrets so they are easy to spot in logs and
+// secret scanners.
+const apiKeyPrefix = "mak_"
+
+func hashAPIKey(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateAPIKey stores a new key and returns it with its secret. The secret
+// is not stored and cannot be shown again.
+func (s *RedisService) CreateAPIKey(req *models.CreateAPIKeyRequest, createdBy int64) (*models.APIKey, string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return nil, "", fmt.Errorf("failed to generate API key: %v", err)
+	}
+	secret := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(buf)
+
+	rateLimit := req.RateLimit
+	if rateLimit == 0 {
+		rateLimit = models.DefaultAPIKeyRateLimit
+	}
+
+	key := &models.APIKey{
+		ID:         uuid.New().String(),
+		Name:       req.Name,
+		Prefix:     secret[:len(apiKeyPrefix)+6],
+		Scopes:     req.Scopes,
+		AllowedIPs: req.AllowedIPs,
+		RateLimit:  rateLimit,
+		CreatedBy:  createdBy,
+		CreatedAt:  time.Now(),
+	}
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to marshal API key: %v", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(s.ctx, KeyAPIKeys, key.ID, data)
+	pipe.HSet(s.ctx, KeyAPIKeyHashes, hashAPIKey(secret), key.ID)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return nil, "", fmt.Errorf("failed to store API key: %v", err)
+	}
+
+	return key, secret, nil
+}
+
+// AuthenticateAPIKey resolves a secret to its key and records the use.
+func (s *RedisService) AuthenticateAPIKey(secret string) (*models.APIKey, error) {
+	id, err := s.client.HGet(s.ctx, KeyAPIKeyHashes, hashAPIKey(secret)).Result()
+	if err == redis.Nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up API key: %v", err)
+	}
+
+	key, err := s.GetAPIKey(id)
+	if err == ErrAPIKeyNotFound {
+		return nil, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.Revoked {
+		return nil, ErrAPIKeyInvalid
+	}
+
+	key.LastUsedAt = time.Now()
+	s.client.HSet(s.ctx, KeyAPIKeyLastUsed, key.ID, key.LastUsedAt.Unix())
+
+	return key, nil
+}
+
+func (s *RedisService) GetAPIKey(id string) (*models.APIKey, error) {
+	data, err := s.client.HGet(s.ctx, KeyAPIKeys, id).Result()
+	if err == redis.Nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %v", err)
+	}
+
+	var key models.APIKey
+	if err := json.Unmarshal([]byte(data), &key); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal API key: %v", err)
+	}
+	return &key, nil
+}
+
+// ListAPIKeys returns every key, revoked ones included, oldest first.
+func (s *RedisService) ListAPIKeys() ([]*models.APIKey, error) {
+	records, err := s.client.HGetAll(s.ctx, KeyAPIKeys).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %v", err)
+	}
+	lastUsed, err := s.client.HGetAll(s.ctx, KeyAPIKeyLastUsed).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %v", err)
+	}
+
+	keys := make([]*models.APIKey, 0, len(records))
+	for id, data := range records {
+		var key models.APIKey
+		if err := json.Unmarshal([]byte(data), &key); err != nil {
+			continue
+		}
+		if unix, err := strconv.ParseInt(lastUsed[id], 10, 64); err == nil {
+			key.LastUsedAt = time.Unix(unix, 0)
+		}
+		keys = append(keys, &key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+// RevokeAPIKey disables a key at once. The record is kept so the audit log
+// still resolves its ID.
+func (s *RedisService) RevokeAPIKey(id string) (*models.APIKey, error) {
+	key, err := s.GetAPIKey(id)
+	if err != nil {
+		return nil, err
+	}
+	if key.Revoked {
+		return key, nil
+	}
+
+	key.Revoked = true
+	key.RevokedAt = time.Now()
+
+	data, err := json.Marshal(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal API key: %v", err)
+	}
+
+	// The secret is not stored, so the hash index is searched for the ID
+	hashes, err := s.client.HGetAll(s.ctx, KeyAPIKeyHashes).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %v", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.HSet(s.ctx, KeyAPIKeys, key.ID, data)
+	for hash, keyID := range hashes {
+		if keyID == key.ID {
+			pipe.HDel(s.ctx, KeyAPIKeyHashes, hash)
+		}
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to revoke API key: %v", err)
+	}
+
+	return key, nil
+}
+
+// CheckAPIKeyRateLimit counts a request against the key's per-minute limit.
+func (s *RedisService) CheckAPIKeyRateLimit(key *models.APIKey) (bool, error) {
+	counter := fmt.Sprintf(KeyAPIKeyRateLimit, key.ID)
+
+	count, err := s.client.Incr(s.ctx, counter).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check rate limit: %v", err)
+	}
+
+	if count == 1 {
+		s.client.Expire(s.ctx, counter, time.Minute)
+	}
+
+	return count <= int64(key.RateLimit), nil
+}
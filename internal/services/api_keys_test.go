@@ -0,0 +1,80 @@
+package services_test
+
+import (
+	"testing"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestAPIKeys(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	key, secret, err := redisService.CreateAPIKey(&models.CreateAPIKeyRequest{
+		Name:      "test worker",
+		Scopes:    []models.APIScope{models.ScopePlayersRead},
+		RateLimit: 2,
+	}, 1)
+	if err != nil {
+		t.Fatalf("Failed to create API key: %v", err)
+	}
+	defer redisService.RevokeAPIKey(key.ID)
+
+	if key.Prefix == "" || secret[:len(key.Prefix)] != key.Prefix {
+		t.Errorf("Expected prefix %q to start the secret", key.Prefix)
+	}
+
+	authed, err := redisService.AuthenticateAPIKey(secret)
+	if err != nil || authed.ID != key.ID {
+		t.Fatalf("Expected the secret to resolve to the key, got %+v (%v)", authed, err)
+	}
+	if _, err := redisService.AuthenticateAPIKey(secret + "x"); err != services.ErrAPIKeyInvalid {
+		t.Errorf("Expected a wrong secret to be rejected, got %v", err)
+	}
+
+	for i, want := range []bool{true, true, false} {
+		allowed, err := redisService.CheckAPIKeyRateLimit(key)
+		if err != nil || allowed != want {
+			t.Errorf("Request %d: expected allowed=%v, got %v (%v)", i+1, want, allowed, err)
+		}
+	}
+
+	keys, err := redisService.ListAPIKeys()
+	if err != nil {
+		t.Fatalf("Failed to list API keys: %v", err)
+	}
+	found := false
+	for _, k := range keys {
+		if k.ID == key.ID {
+			found = true
+			if k.LastUsedAt.IsZero() {
+				t.Error("Expected last use to be recorded")
+			}
+		}
+	}
+	if !found {
+		t.Error("Expected the new key in the list")
+	}
+
+	revoked, err := redisService.RevokeAPIKey(key.ID)
+	if err != nil || !revoked.Revoked {
+		t.Fatalf("Failed to revoke API key: %+v (%v)", revoked, err)
+	}
+	if _, err := redisService.AuthenticateAPIKey(secret); err != services.ErrAPIKeyInvalid {
+		t.Errorf("Expected a revoked key to be rejected, got %v", err)
+	}
+	if _, err := redisService.RevokeAPIKey("missing"); err != services.ErrAPIKeyNotFound {
+		t.Errorf("Expected not found for an unknown key, got %v", err)
+	}
+}
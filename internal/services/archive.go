@@ -0,0 +1,364 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"sample-miniapp-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ArchiveStore is cold storage for settled game sessions.
+type ArchiveStore interface {
+	// Put stores a batch of sessions and returns, for each one, the
+	// location it was written to, which is handed back to Get.
+	Put(sessions []*models.GameSession) ([]string, error)
+	Get(location, gameID string) (*models.GameSession, error)
+}
+
+// FileArchiveStore keeps one gzip bundle of JSON lines per day. Each
+// session is its own gzip member, so its location is the bundle plus the
+// member's byte offset and Get decompresses just that one session. Readers
+// that ignore the offsets still see one continuous stream.
+//
+// The directory must be shared by every instance that serves game history.
+type FileArchiveStore struct {
+	dir string
+	mu  sync.Mutex
+}
+
+func NewFileArchiveStore(dir string) (*FileArchiveStore, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive dir: %v", err)
+	}
+	return &FileArchiveStore{dir: dir}, nil
+}
+
+func (fs *FileArchiveStore) Put(sessions []*models.GameSession) ([]string, error) {
+	if len(sessions) == 0 {
+		return nil, nil
+	}
+
+	bundle := "sessions-" + sessions[0].EndedAt.UTC().Format("20060102") + ".jsonl.gz"
+
+	var buf bytes.Buffer
+	offsets := make([]int, len(sessions))
+	for i, session := range sessions {
+		offsets[i] = buf.Len()
+
+		zw := gzip.NewWriter(&buf)
+		if err := json.NewEncoder(zw).Encode(session); err != nil {
+			return nil, fmt.Errorf("failed to encode archived session: %v", err)
+		}
+		if err := zw.Close(); err != nil {
+			return nil, fmt.Errorf("failed to compress archived session: %v", err)
+		}
+	}
+
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	f, err := os.OpenFile(filepath.Join(fs.dir, bundle), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o640)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive bundle: %v", err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat archive bundle: %v", err)
+	}
+	start := info.Size()
+
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		return nil, fmt.Errorf("failed to write archive bundle: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		return nil, fmt.Errorf("failed to sync archive bundle: %v", err)
+	}
+
+	locations := make([]string, len(sessions))
+	for i, offset := range offsets {
+		locations[i] = bundle + "@" + strconv.FormatInt(start+int64(offset), 10)
+	}
+	return locations, nil
+}
+
+func (fs *FileArchiveStore) Get(location, gameID string) (*models.GameSession, error) {
+	bundle, offset := location, int64(-1)
+	if i := strings.LastIndex(location, "@"); i >= 0 {
+		parsed, err := strconv.ParseInt(location[i+1:], 10, 64)
+		if err != nil || parsed < 0 {
+			return nil, fmt.Errorf("invalid archive location: %s", location)
+		}
+		bundle, offset = location[:i], parsed
+	}
+
+	// Locations come from our own index, but never let one escape the dir
+	if bundle != filepath.Base(bundle) {
+		return nil, fmt.Errorf("invalid archive location: %s", location)
+	}
+
+	f, err := os.Open(filepath.Join(fs.dir, bundle))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive bundle: %v", err)
+	}
+	defer f.Close()
+
+	if offset >= 0 {
+		if _, err := f.Seek(offset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek archive bundle: %v", err)
+		}
+	}
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive bundle: %v", err)
+	}
+	defer zr.Close()
+
+	// With an offset, the session is the only line of its member. Locations
+	// written before offsets were recorded scan the whole bundle.
+	if offset >= 0 {
+		zr.Multistream(false)
+	}
+
+	needle := `"id":"` + gameID + `"`
+	scanner := bufio.NewScanner(zr)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if !strings.Contains(string(line), needle) {
+			continue
+		}
+
+		var session models.GameSession
+		if err := json.Unmarshal(line, &session); err != nil {
+			return nil, fmt.Errorf("failed to decode archived session: %v", err)
+		}
+		if session.ID == gameID {
+			return &session, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to scan archive bundle: %v", err)
+	}
+
+	return nil, fmt.Errorf("game %s not in archive bundle %s", gameID, location)
+}
+
+// SetArchiveStore enables read-through to cold storage for game sessions
+// that are no longer in Redis.
+func (s *RedisService) SetArchiveStore(store ArchiveStore) {
+	s.archive = store
+}
+
+func (s *RedisService) getArchivedGameSession(gameID string) (*models.GameSession, error) {
+	if s.archive == nil {
+		return nil, fmt.Errorf("game not found: %s", gameID)
+	}
+
+	location, err := s.client.HGet(s.ctx, archiveIndexKey(gameID), gameID).Result()
+	if err == redis.Nil {
+		location, err = s.client.HGet(s.ctx, KeyArchiveIndexLegacy, gameID).Result()
+	}
+	if err == redis.Nil {
+		return nil, fmt.Errorf("game not found: %s", gameID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive index: %v", err)
+	}
+
+	return s.archive.Get(location, gameID)
+}
+
+// archiveIndexKey picks one of 256 hashes for a game's index entry. The
+// index keeps an entry per archived game for good, so it is split to keep
+// any one key small.
+func archiveIndexKey(gameID string) string {
+	h := fnv.New32a()
+	h.Write([]byte(gameID))
+	return fmt.Sprintf(KeyArchiveIndex, fmt.Sprintf("%02x", h.Sum32()%256))
+}
+
+// AcquireArchiveLock makes sure only one instance archives at a time. The
+// lock is left to expire so each interval gets at most one run.
+func (s *RedisService) AcquireArchiveLock(ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(s.ctx, KeyArchiveLock, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire archive lock: %v", err)
+	}
+	return ok, nil
+}
+
+// archivePageSize is how many session keys are read per SCAN page, which
+// bounds what one archiver step holds in memory.
+const archivePageSize = 500
+
+// GameArchiver moves settled sessions out of Redis before TTLGameSession
+// would silently drop them.
+type GameArchiver struct {
+	redisService *RedisService
+	store        ArchiveStore
+	olderThan    time.Duration
+	interval     time.Duration
+}
+
+// NewGameArchiver fails unless a session ending now is archived, one run
+// interval later at the latest, before TTLGameSession drops it. Otherwise
+// sessions would expire unarchived and take their fairness evidence along.
+func NewGameArchiver(redisService *RedisService, store ArchiveStore, olderThan, interval time.Duration) (*GameArchiver, error) {
+	if olderThan <= 0 || interval <= 0 {
+		return nil, fmt.Errorf("archive age and interval must be positive")
+	}
+	if olderThan+interval >= TTLGameSession {
+		return nil, fmt.Errorf("archiving after %s every %s misses sessions that expire after %s",
+			olderThan, interval, TTLGameSession)
+	}
+
+	return &GameArchiver{
+		redisService: redisService,
+		store:        store,
+		olderThan:    olderThan,
+		interval:     interval,
+	}, nil
+}
+
+func (ga *GameArchiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(ga.interval)
+	defer ticker.Stop()
+
+	for {
+		ga.runLocked(ga.interval)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runLocked archives unless another instance already ran this interval;
+// concurrent runs would archive the same sessions twice.
+func (ga *GameArchiver) runLocked(interval time.Duration) {
+	ok, err := ga.redisService.AcquireArchiveLock(interval)
+	if err != nil {
+		log.Printf("Game archiver: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	if n, err := ga.ArchiveOnce(); err != nil {
+		log.Printf("Game archiver: %v", err)
+	} else if n > 0 {
+		log.Printf("Game archiver: archived %d sessions", n)
+	}
+}
+
+// ArchiveOnce archives every settled session that ended before the
+// threshold and returns how many were moved. It works one SCAN page at a
+// time, so memory stays bounded however many sessions Redis holds.
+func (ga *GameArchiver) ArchiveOnce() (int, error) {
+	s := ga.redisService
+	cutoff := time.Now().Add(-ga.olderThan)
+
+	archived := 0
+	var cursor uint64
+	for {
+		keys, next, err := s.client.Scan(s.ctx, cursor, fmt.Sprintf(KeyGameSession, "*"), archivePageSize).Result()
+		if err != nil {
+			return archived, fmt.Errorf("failed to scan game sessions: %v", err)
+		}
+
+		n, err := ga.archivePage(keys, cutoff)
+		archived += n
+		if err != nil {
+			return archived, err
+		}
+
+		if next == 0 {
+			return archived, nil
+		}
+		cursor = next
+	}
+}
+
+// archivePage archives the eligible sessions among one page of keys.
+func (ga *GameArchiver) archivePage(keys []string, cutoff time.Time) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+
+	s := ga.redisService
+	values, err := s.client.MGet(s.ctx, keys...).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to read game sessions: %v", err)
+	}
+
+	byDay := make(map[string][]*models.GameSession)
+	for _, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // expired since the scan
+		}
+
+		var session models.GameSession
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			continue
+		}
+
+		if session.Status == models.GameStatusActive || session.EndedAt.IsZero() || session.EndedAt.After(cutoff) {
+			continue
+		}
+
+		day := session.EndedAt.UTC().Format("20060102")
+		byDay[day] = append(byDay[day], &session)
+	}
+
+	archived := 0
+	for _, sessions := range byDay {
+		locations, err := ga.store.Put(sessions)
+		if err != nil {
+			return archived, err
+		}
+
+		// Index first, delete second: a crash in between leaves a duplicate,
+		// never a hole.
+		pipe := s.client.Pipeline()
+		for i, session := range sessions {
+			pipe.HSet(s.ctx, archiveIndexKey(session.ID), session.ID, locations[i])
+		}
+		if _, err := pipe.Exec(s.ctx); err != nil {
+			return archived, fmt.Errorf("failed to index archived sessions: %v", err)
+		}
+
+		pipe = s.client.Pipeline()
+		for _, session := range sessions {
+			pipe.Del(s.ctx, fmt.Sprintf(KeyGameSession, session.ID))
+		}
+		if _, err := pipe.Exec(s.ctx); err != nil {
+			return archived, fmt.Errorf("failed to remove archived sessions: %v", err)
+		}
+
+		archived += len(sessions)
+	}
+
+	return archived, nil
+}
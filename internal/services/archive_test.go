@@ -0,0 +1,147 @@
+package services_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestFileArchiveStore(t *testing.T) {
+	store, err := services.NewFileArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create archive store: %v", err)
+	}
+
+	endedAt := time.Date(2025, 1, 2, 15, 0, 0, 0, time.UTC)
+	first := &models.GameSession{ID: "archived_1", UserID: 1, Status: models.GameStatusCrashed, EndedAt: endedAt}
+	second := &models.GameSession{ID: "archived_2", UserID: 1, Status: models.GameStatusCashedOut, EndedAt: endedAt, CashoutAt: 2.5}
+	third := &models.GameSession{ID: "archived_3", UserID: 2, Status: models.GameStatusLost, EndedAt: endedAt}
+
+	locs1, err := store.Put([]*models.GameSession{first})
+	if err != nil {
+		t.Fatalf("Failed to archive first batch: %v", err)
+	}
+
+	// A second batch for the same day lands in the same bundle, one gzip
+	// member per session
+	locs2, err := store.Put([]*models.GameSession{second, third})
+	if err != nil {
+		t.Fatalf("Failed to archive second batch: %v", err)
+	}
+
+	if len(locs1) != 1 || len(locs2) != 2 {
+		t.Fatalf("Expected one location per session, got %v and %v", locs1, locs2)
+	}
+	bundle := func(loc string) string { return loc[:strings.LastIndex(loc, "@")] }
+	if bundle(locs1[0]) != bundle(locs2[0]) || bundle(locs2[0]) != bundle(locs2[1]) {
+		t.Errorf("Same-day sessions should share a bundle, got %v and %v", locs1, locs2)
+	}
+	if locs1[0] == locs2[0] || locs2[0] == locs2[1] {
+		t.Errorf("Each session should have its own offset, got %v and %v", locs1, locs2)
+	}
+
+	got, err := store.Get(locs2[0], "archived_2")
+	if err != nil {
+		t.Fatalf("Failed to read archived session: %v", err)
+	}
+	if got.Status != models.GameStatusCashedOut || got.CashoutAt != 2.5 {
+		t.Errorf("Archived session mismatch: %+v", got)
+	}
+
+	if _, err := store.Get(locs1[0], "archived_1"); err != nil {
+		t.Errorf("First batch should still be readable: %v", err)
+	}
+	if _, err := store.Get(locs2[1], "archived_3"); err != nil {
+		t.Errorf("Last session of a batch should be readable: %v", err)
+	}
+
+	// An offset only holds its own session
+	if _, err := store.Get(locs2[0], "archived_3"); err == nil {
+		t.Error("Lookup at another session's offset should fail")
+	}
+
+	// Locations without an offset scan the whole bundle
+	if _, err := store.Get(bundle(locs2[1]), "archived_3"); err != nil {
+		t.Errorf("Bundle-only location should still be readable: %v", err)
+	}
+
+	if _, err := store.Get("../"+locs1[0], "archived_1"); err == nil {
+		t.Error("Locations outside the archive dir should be rejected")
+	}
+	if _, err := store.Get(bundle(locs1[0])+"@-1", "archived_1"); err == nil {
+		t.Error("Negative offsets should be rejected")
+	}
+}
+
+func TestNewGameArchiverChecksSessionTTL(t *testing.T) {
+	cases := []struct {
+		olderThan time.Duration
+		ok        bool
+	}{
+		{48 * time.Hour, true},
+		{services.TTLGameSession - 2*time.Hour, true},
+		{services.TTLGameSession - time.Hour, false},
+		{services.TTLGameSession, false},
+		{8 * 24 * time.Hour, false},
+		{-time.Hour, false},
+		{0, false},
+	}
+
+	for _, tc := range cases {
+		_, err := services.NewGameArchiver(nil, nil, tc.olderThan, time.Hour)
+		if (err == nil) != tc.ok {
+			t.Errorf("NewGameArchiver(%s) error = %v, want ok %v", tc.olderThan, err, tc.ok)
+		}
+	}
+}
+
+func TestGameArchiverArchiveOnce(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	store, err := services.NewFileArchiveStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create archive store: %v", err)
+	}
+	redisService.SetArchiveStore(store)
+
+	userID := time.Now().UnixNano() % 1000000000
+	session := &models.GameSession{
+		ID:      fmt.Sprintf("test_archive_%d", userID),
+		UserID:  userID,
+		Status:  models.GameStatusCrashed,
+		EndedAt: time.Now().Add(-72 * time.Hour),
+	}
+	if err := redisService.SaveGameSession(session); err != nil {
+		t.Fatalf("Failed to save game session: %v", err)
+	}
+	defer redisService.DeleteUserSession(userID, session.ID)
+
+	archiver, err := services.NewGameArchiver(redisService, store, 48*time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create archiver: %v", err)
+	}
+	if _, err := archiver.ArchiveOnce(); err != nil {
+		t.Fatalf("Failed to archive: %v", err)
+	}
+
+	// Gone from Redis, still readable through the sharded index
+	archived, err := redisService.GetGameSession(session.ID)
+	if err != nil || archived.ID != session.ID || archived.Status != models.GameStatusCrashed {
+		t.Errorf("Expected the session back from the archive, got %+v (%v)", archived, err)
+	}
+}
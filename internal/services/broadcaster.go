@@ -1,6 +1,21 @@
 package services
 
+import "sample-miniapp-backend/internal/models"
+
 type Broadcaster interface {
 	BroadcastGameUpdate(gameID string, multiplier float64)
 	BroadcastGameCrash(gameID string, crashPoint float64)
+
+	// JoinGame subscribes the player's connection to their own game room.
+	JoinGame(userID int64, gameID string)
+	// CloseGame drops every subscription to a finished game.
+	CloseGame(gameID string)
+
+	// BroadcastFeed pushes a settled bet to the public feeds.
+	BroadcastFeed(entry *models.FeedEntry)
+}
+
+// WalletNotifier is told about every committed wallet change.
+type WalletNotifier interface {
+	BroadcastBalance(wallet *models.Wallet, reason string)
 }
@@ -0,0 +1,275 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"sample-miniapp-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// PublishEvent appends an event to the casino event stream and returns the
+// stream entry ID.
+func (s *RedisService) PublishEvent(event *models.Event) (string, error) {
+	if event.Version == 0 {
+		event.Version = models.EventSchemaVersion
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal event: %v", err)
+	}
+
+	id, err := s.client.XAdd(s.ctx, &redis.XAddArgs{
+		Stream: KeyEventStream,
+		MaxLen: EventStreamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{
+			"type":  string(event.Type),
+			"event": data,
+		},
+	}).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to publish event: %v", err)
+	}
+
+	event.ID = id
+	return id, nil
+}
+
+// publishEvent is the fire-and-forget variant used on hot paths where a
+// failure to log must not fail the player's action.
+func (s *RedisService) publishEvent(event *models.Event) {
+	if _, err := s.PublishEvent(event); err != nil {
+		log.Printf("Failed to publish %s event: %v", event.Type, err)
+	}
+}
+
+// ReadEvents returns up to count events after fromID ("0" for the start of
+// the stream). It does not involve consumer groups and is meant for replays.
+func (s *RedisService) ReadEvents(fromID string, count int64) ([]*models.Event, error) {
+	if fromID == "" {
+		fromID = "0"
+	}
+
+	messages, err := s.client.XRangeN(s.ctx, KeyEventStream, "("+fromID, "+", count).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %v", err)
+	}
+
+	return decodeEvents(messages), nil
+}
+
+// EnsureEventGroup creates a consumer group on the event stream if it does
+// not exist yet. startID is where a new group begins: "$" for new events
+// only, "0" for the whole retained history.
+func (s *RedisService) EnsureEventGroup(group, startID string) error {
+	err := s.client.XGroupCreateMkStream(s.ctx, KeyEventStream, group, startID).Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group %s: %v", group, err)
+	}
+	return nil
+}
+
+// ReplayEventGroup moves a consumer group's cursor so its next read starts
+// after fromID. Use "0" to replay everything still in the stream.
+func (s *RedisService) ReplayEventGroup(group, fromID string) error {
+	if err := s.client.XGroupSetID(s.ctx, KeyEventStream, group, fromID).Err(); err != nil {
+		return fmt.Errorf("failed to reset consumer group %s: %v", group, err)
+	}
+	return nil
+}
+
+// ReadEventGroup reads events for one consumer in a group. Passing ">" as
+// fromID returns new events; "0" returns this consumer's unacknowledged ones.
+func (s *RedisService) ReadEventGroup(ctx context.Context, group, consumer, fromID string, count int64, block time.Duration) ([]*models.Event, error) {
+	streams, err := s.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{KeyEventStream, fromID},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read consumer group %s: %v", group, err)
+	}
+
+	var events []*models.Event
+	for _, stream := range streams {
+		events = append(events, decodeEvents(stream.Messages)...)
+	}
+	return events, nil
+}
+
+// ClaimStaleEvents takes over events another consumer of the group read but
+// has not acked for at least minIdle, e.g. because its instance died. It
+// returns the claimed events and the cursor to continue from; "0-0" means
+// the pending list was walked to the end.
+func (s *RedisService) ClaimStaleEvents(ctx context.Context, group, consumer, start string, minIdle time.Duration, count int64) ([]*models.Event, string, error) {
+	messages, next, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   KeyEventStream,
+		Group:    group,
+		Consumer: consumer,
+		MinIdle:  minIdle,
+		Start:    start,
+		Count:    count,
+	}).Result()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to claim events for %s: %v", group, err)
+	}
+	return decodeEvents(messages), next, nil
+}
+
+func (s *RedisService) AckEvents(group string, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.client.XAck(s.ctx, KeyEventStream, group, ids...).Err()
+}
+
+func decodeEvents(messages []redis.XMessage) []*models.Event {
+	events := make([]*models.Event, 0, len(messages))
+	for _, msg := range messages {
+		raw, ok := msg.Values["event"].(string)
+		if !ok {
+			continue
+		}
+
+		var event models.Event
+		if err := json.Unmarshal([]byte(raw), &event); err != nil {
+			log.Printf("Skipping malformed event %s: %v", msg.ID, err)
+			continue
+		}
+		event.ID = msg.ID
+		events = append(events, &event)
+	}
+	return events
+}
+
+// EventConsumer reads the event stream as one member of a consumer group.
+// Each group (analytics, notifications, risk) gets its own cursor, so they
+// progress independently.
+type EventConsumer struct {
+	redisService *RedisService
+	group        string
+	name         string
+	batchSize    int64
+	block        time.Duration
+	// claimIdle is how long an event may sit unacked with another consumer
+	// before this one takes it over
+	claimIdle time.Duration
+}
+
+// InstanceConsumerName names a consumer after this process, so instances
+// sharing a group never read each other's pending events as their own.
+func InstanceConsumerName(prefix string) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s-%s-%d", prefix, host, os.Getpid())
+}
+
+func NewEventConsumer(redisService *RedisService, group, name string) *EventConsumer {
+	return &EventConsumer{
+		redisService: redisService,
+		group:        group,
+		name:         name,
+		batchSize:    100,
+		block:        5 * time.Second,
+		claimIdle:    time.Minute,
+	}
+}
+
+// Run delivers events to handle until ctx is cancelled. Events are acked
+// only when handle returns nil; failed ones stay pending and are retried
+// the next time the consumer starts. Events left pending by a consumer
+// that went away, or that failed here, are claimed and retried once they
+// have been idle for claimIdle.
+func (ec *EventConsumer) Run(ctx context.Context, handle func(*models.Event) error) error {
+	if err := ec.redisService.EnsureEventGroup(ec.group, "$"); err != nil {
+		return err
+	}
+
+	// Drain anything this consumer read but never acked before a restart.
+	cursor := "0"
+	var lastClaim time.Time
+
+	for ctx.Err() == nil {
+		if cursor == ">" && time.Since(lastClaim) >= ec.claimIdle {
+			ec.claimStale(ctx, handle)
+			lastClaim = time.Now()
+		}
+
+		events, err := ec.redisService.ReadEventGroup(ctx, ec.group, ec.name, cursor, ec.batchSize, ec.block)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			log.Printf("Event consumer %s/%s: %v", ec.group, ec.name, err)
+			time.Sleep(time.Second)
+			continue
+		}
+
+		if cursor == "0" && len(events) == 0 {
+			cursor = ">"
+			continue
+		}
+
+		acked := ec.handle(events, handle)
+
+		if cursor == "0" && len(acked) < len(events) {
+			// Pending entries that keep failing would otherwise be re-read forever.
+			cursor = ">"
+		}
+	}
+
+	return ctx.Err()
+}
+
+// claimStale walks the group's pending list once, taking over and handling
+// every event that has been idle for claimIdle.
+func (ec *EventConsumer) claimStale(ctx context.Context, handle func(*models.Event) error) {
+	start := "0-0"
+	for ctx.Err() == nil {
+		events, next, err := ec.redisService.ClaimStaleEvents(ctx, ec.group, ec.name, start, ec.claimIdle, ec.batchSize)
+		if err != nil {
+			log.Printf("Event consumer %s/%s: %v", ec.group, ec.name, err)
+			return
+		}
+		ec.handle(events, handle)
+		if next == "0-0" || next == "" {
+			return
+		}
+		start = next
+	}
+}
+
+// handle runs handle on each event and acks the ones that succeeded.
+func (ec *EventConsumer) handle(events []*models.Event, handle func(*models.Event) error) []string {
+	var acked []string
+	for _, event := range events {
+		if err := handle(event); err != nil {
+			log.Printf("Event consumer %s/%s failed on %s: %v", ec.group, ec.name, event.ID, err)
+			continue
+		}
+		acked = append(acked, event.ID)
+	}
+
+	if err := ec.redisService.AckEvents(ec.group, acked...); err != nil {
+		log.Printf("Event consumer %s/%s ack failed: %v", ec.group, ec.name, err)
+	}
+	return acked
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"sample-miniapp-backend/internal/models"
+)
+
+func feedKey(feed models.FeedType) string {
+	if feed == models.FeedHighRollers {
+		return KeyFeedHighRollers
+	}
+	return KeyFeedAll
+}
+
+// AddFeedEntry records a settled bet on the public feed, and on the high
+// rollers feed when it qualifies.
+func (s *RedisService) AddFeedEntry(entry *models.FeedEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to marshal feed entry: %v", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.LPush(s.ctx, KeyFeedAll, data)
+	pipe.LTrim(s.ctx, KeyFeedAll, 0, FeedMaxLen-1)
+	if entry.IsHighRoller() {
+		pipe.LPush(s.ctx, KeyFeedHighRollers, data)
+		pipe.LTrim(s.ctx, KeyFeedHighRollers, 0, FeedMaxLen-1)
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return fmt.Errorf("failed to add feed entry: %v", err)
+	}
+	return nil
+}
+
+// GetFeed returns the newest entries first.
+func (s *RedisService) GetFeed(feed models.FeedType, limit int64) ([]*models.FeedEntry, error) {
+	if limit <= 0 || limit > FeedMaxLen {
+		limit = FeedMaxLen
+	}
+
+	items, err := s.client.LRange(s.ctx, feedKey(feed), 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get feed: %v", err)
+	}
+
+	entries := make([]*models.FeedEntry, 0, len(items))
+	for _, item := range items {
+		var entry models.FeedEntry
+		if err := json.Unmarshal([]byte(item), &entry); err != nil {
+			continue
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+func (s *RedisService) SetFeedOptOut(userID int64, hidden bool) error {
+	var err error
+	if hidden {
+		err = s.client.SAdd(s.ctx, KeyFeedOptOut, userID).Err()
+	} else {
+		err = s.client.SRem(s.ctx, KeyFeedOptOut, userID).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to update feed privacy: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisService) IsFeedOptOut(userID int64) (bool, error) {
+	hidden, err := s.client.SIsMember(s.ctx, KeyFeedOptOut, userID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to read feed privacy: %v", err)
+	}
+	return hidden, nil
+}
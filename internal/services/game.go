@@ -5,11 +5,13 @@ import (
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
 	"math"
 	"math/big"
 	"math/rand"
+	"sync"
 	"time"
 
 	"sample-miniapp-backend/internal/models"
@@ -17,19 +19,40 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	ErrGameNotFound  = errors.New("game not found")
+	ErrNotGameOwner  = errors.New("you don't own this game")
+	ErrGameNotActive = errors.New("game is not active")
+	// ErrGameChanged means the stored game moved on since it was read
+	ErrGameChanged = errors.New("game changed concurrently, reload and retry")
+)
+
 type GameEngine struct {
 	redisService *RedisService
 	serverSeed   string
+	seedMu       sync.RWMutex
 	activeGames  map[string]*GameInstance
+	gamesMu      sync.RWMutex
 	broadcaster  Broadcaster
 }
 
 type GameInstance struct {
+	// mu guards Session, which the game loop and player actions both
+	// change
+	mu         sync.Mutex
 	Session    *models.GameSession
 	StartedAt  time.Time
 	LastUpdate time.Time
 	IsRunning  bool
 	StopChan   chan struct{}
+	stopOnce   sync.Once
+}
+
+// Stop signals the instance's game loop to exit. Safe to call more than once.
+func (gi *GameInstance) Stop() {
+	gi.stopOnce.Do(func() {
+		close(gi.StopChan)
+	})
 }
 
 func NewGameEngine(redisService *RedisService) *GameEngine {
@@ -53,20 +76,32 @@ func generateServerSeed() string {
 }
 
 func (ge *GameEngine) GetServerHash() string {
-	hash := sha256.Sum256([]byte(ge.serverSeed))
+	return HashServerSeed(ge.GetServerSeed())
+}
+
+// HashServerSeed is the commitment published to players before a seed is
+// revealed.
+func HashServerSeed(serverSeed string) string {
+	hash := sha256.Sum256([]byte(serverSeed))
 	return hex.EncodeToString(hash[:])
 }
 
 func (ge *GameEngine) GetServerSeed() string {
+	ge.seedMu.RLock()
+	defer ge.seedMu.RUnlock()
 	return ge.serverSeed
 }
 
-func (ge *GameEngine) generateCrashPoint(clientSeed string, nonce int64) float64 {
+func (ge *GameEngine) generateCrashPoint(clientSeed string, nonce int64, cfg *models.GameConfig) float64 {
 	message := fmt.Sprintf("%s:%d", clientSeed, nonce)
-	h := hmac.New(sha256.New, []byte(ge.serverSeed))
+	h := hmac.New(sha256.New, []byte(ge.GetServerSeed()))
 	h.Write([]byte(message))
 	hash := hex.EncodeToString(h.Sum(nil))
 
+	return crashPointFromHash(hash, cfg.HouseEdge, cfg.MaxMultiplier)
+}
+
+func crashPointFromHash(hash string, houseEdge, maxMultiplier float64) float64 {
 	// Standard crash game formula:
 	// Use first 52 bits (13 hex characters) of hash
 	hashPrefix := hash[:13]
@@ -78,45 +113,35 @@ func (ge *GameEngine) generateCrashPoint(clientSeed string, nonce int64) float64
 	// Calculate crash point with house edge
 	// Common formula: e = 0.99 (1% house edge)
 	// crashPoint = floor(100 * (1 - e) / (1 - randFloat))
-	houseEdge := 0.01 // 1% house edge
 	crashPoint := math.Floor(100*(1-houseEdge)/(1-randFloat)) / 100.0
 
-	// Ensure minimum 1.00x and maximum 1000x
+	// Ensure minimum 1.00x and the configured maximum
 	if crashPoint < 1.0 {
 		crashPoint = 1.0
 	}
-	if crashPoint > 1000.0 {
-		crashPoint = 1000.0
+	if crashPoint > maxMultiplier {
+		crashPoint = maxMultiplier
 	}
 
 	return crashPoint
 }
 
-// VerifyGameResult allows players to verify game fairness
+// VerifyGameResult allows players to verify game fairness. It assumes the
+// default crash configuration; use VerifyCrashPoint for games that recorded
+// their own.
 func (ge *GameEngine) VerifyGameResult(clientSeed, serverSeed string, nonce int64) (float64, string, error) {
+	defaults := models.DefaultGameConfig(models.GameTypeCrash)
+	return ge.VerifyCrashPoint(clientSeed, serverSeed, nonce, defaults.HouseEdge, defaults.MaxMultiplier)
+}
+
+func (ge *GameEngine) VerifyCrashPoint(clientSeed, serverSeed string, nonce int64, houseEdge, maxMultiplier float64) (float64, string, error) {
 	// Recalculate the hash
 	message := fmt.Sprintf("%s:%d", clientSeed, nonce)
 	h := hmac.New(sha256.New, []byte(serverSeed))
 	h.Write([]byte(message))
 	calculatedHash := hex.EncodeToString(h.Sum(nil))
 
-	// Generate crash point from hash
-	hashPrefix := calculatedHash[:13]
-	n := new(big.Int)
-	n.SetString(hashPrefix, 16)
-
-	randFloat := float64(n.Int64()) / math.Pow(2, 52)
-	houseEdge := 0.01
-	crashPoint := math.Floor(100*(1-houseEdge)/(1-randFloat)) / 100.0
-
-	if crashPoint < 1.0 {
-		crashPoint = 1.0
-	}
-	if crashPoint > 1000.0 {
-		crashPoint = 1000.0
-	}
-
-	return crashPoint, calculatedHash, nil
+	return crashPointFromHash(calculatedHash, houseEdge, maxMultiplier), calculatedHash, nil
 }
 
 // GetVerificationData returns data needed for client verification
@@ -138,6 +163,18 @@ func (ge *GameEngine) PlaceBet(ctx context.Context, userID int64, req *models.Be
 		return nil, fmt.Errorf("invalid bet: %v", err)
 	}
 
+	cfg, err := ge.redisService.GetGameConfig(req.GameType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load game config: %v", err)
+	}
+	if err := cfg.CheckBet(req.Amount); err != nil {
+		return nil, err
+	}
+
+	if err := ge.redisService.CheckCanMoveMoney(userID); err != nil {
+		return nil, err
+	}
+
 	allowed, err := ge.redisService.CheckRateLimit(userID, "bet", 30, time.Minute)
 	if err != nil {
 		return nil, fmt.Errorf("rate limit check failed: %v", err)
@@ -165,39 +202,48 @@ func (ge *GameEngine) PlaceBet(ctx context.Context, userID int64, req *models.Be
 	var session *models.GameSession
 	switch req.GameType {
 	case models.GameTypeCrash:
-		session, err = ge.createCrashGame(userID, req.Amount)
+		session, err = ge.createCrashGame(userID, req.Amount, cfg)
 	case models.GameTypeMines:
-		session, err = ge.createMinesGame(userID, req.Amount)
+		session, err = ge.createMinesGame(userID, req.Amount, cfg)
 	case models.GameTypeDice:
-		session, err = ge.createDiceGame(userID, req.Amount)
+		session, err = ge.createDiceGame(userID, req.Amount, cfg)
 	default:
-		ge.redisService.ReleaseBalanceFromGame(userID, req.Amount, false, 0)
+		ge.returnStake(userID, req.Amount, "")
 		return nil, fmt.Errorf("game type not yet implemented: %s", req.GameType)
 	}
 
 	if err != nil {
-		ge.redisService.ReleaseBalanceFromGame(userID, req.Amount, false, 0)
+		ge.returnStake(userID, req.Amount, "")
 		return nil, err
 	}
 
 	if err := ge.startGame(session); err != nil {
-		ge.redisService.ReleaseBalanceFromGame(userID, req.Amount, false, 0)
+		ge.returnStake(userID, req.Amount, session.ID)
 		return nil, fmt.Errorf("failed to start game: %v", err)
 	}
 
+	ge.redisService.publishEvent(models.NewGameEvent(models.EventBetPlaced, session))
+
 	return session, nil
 }
 
-func (ge *GameEngine) createCrashGame(userID int64, betAmount float64) (*models.GameSession, error) {
+// returnStake gives back a stake locked for a bet that never got going.
+func (ge *GameEngine) returnStake(userID int64, amount float64, gameID string) {
+	if _, err := ge.redisService.RefundBalanceFromGame(userID, amount, gameID); err != nil {
+		log.Printf("Failed to return %.2f stake to user %d: %v", amount, userID, err)
+	}
+}
+
+func (ge *GameEngine) createCrashGame(userID int64, betAmount float64, cfg *models.GameConfig) (*models.GameSession, error) {
 	wallet, err := ge.redisService.GetWallet(userID)
 	if err != nil {
 		return nil, err
 	}
 
-	crashPoint := ge.generateCrashPoint(wallet.ClientSeed, wallet.Nonce)
+	crashPoint := ge.generateCrashPoint(wallet.ClientSeed, wallet.Nonce, cfg)
 
 	message := fmt.Sprintf("%s:%d", wallet.ClientSeed, wallet.Nonce)
-	h := hmac.New(sha256.New, []byte(ge.serverSeed))
+	h := hmac.New(sha256.New, []byte(ge.GetServerSeed()))
 	h.Write([]byte(message))
 	gameHash := hex.EncodeToString(h.Sum(nil))
 
@@ -210,20 +256,21 @@ func (ge *GameEngine) createCrashGame(userID int64, betAmount float64) (*models.
 		CrashPoint: crashPoint,
 		ClientSeed: wallet.ClientSeed,
 		ServerHash: ge.GetServerHash(),
-		ServerSeed: ge.serverSeed,
+		ServerSeed: ge.GetServerSeed(),
 		Nonce:      wallet.Nonce,
 		FinalHash:  gameHash,
-		Status:     "active",
+		Status:     models.GameStatusActive,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
+		Metadata:   models.NewCrashMetadata(),
 	}
+	applyGameConfig(session, cfg)
 
 	if err := ge.redisService.SaveGameSession(session); err != nil {
 		return nil, err
 	}
 
-	wallet.Nonce++
-	if err := ge.redisService.SaveWallet(wallet); err != nil {
+	if err := ge.redisService.IncrementWalletNonce(userID); err != nil {
 		return nil, err
 	}
 
@@ -239,7 +286,13 @@ func (ge *GameEngine) startGame(session *models.GameSession) error {
 		StopChan:   make(chan struct{}),
 	}
 
+	ge.gamesMu.Lock()
 	ge.activeGames[session.ID] = gameInstance
+	ge.gamesMu.Unlock()
+
+	if ge.broadcaster != nil {
+		ge.broadcaster.JoinGame(session.UserID, session.ID)
+	}
 
 	switch session.GameType {
 	case models.GameTypeCrash:
@@ -262,17 +315,7 @@ func (ge *GameEngine) runCrashGame(instance *GameInstance) {
 	for {
 		select {
 		case <-ticker.C:
-			instance.Session.Multiplier += 0.01
-			instance.Session.UpdatedAt = time.Now()
-
-			ge.redisService.UpdateGameSession(instance.Session)
-
-			if ge.broadcaster != nil {
-				ge.broadcaster.BroadcastGameUpdate(instance.Session.ID, instance.Session.Multiplier)
-			}
-
-			if instance.Session.Multiplier >= instance.Session.CrashPoint {
-				ge.handleCrash(instance)
+			if done := ge.tickCrashGame(instance); done {
 				return
 			}
 
@@ -282,12 +325,55 @@ func (ge *GameEngine) runCrashGame(instance *GameInstance) {
 	}
 }
 
+// tickCrashGame advances the multiplier by one step and crashes the game
+// once it reaches the crash point. It reports whether the game is over.
+func (ge *GameEngine) tickCrashGame(instance *GameInstance) bool {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	// Settled elsewhere (cashout or forced settlement)
+	if instance.Session.Status != models.GameStatusActive {
+		return true
+	}
+
+	previous := instance.Session.Multiplier
+	instance.Session.Multiplier += 0.01
+	instance.Session.UpdatedAt = time.Now()
+	instance.LastUpdate = time.Now()
+	if instance.Session.Metadata != nil && instance.Session.Metadata.Crash != nil {
+		instance.Session.Metadata.Crash.Ticks++
+	}
+
+	if err := ge.redisService.UpdateGameSession(instance.Session); errors.Is(err, models.ErrInvalidTransition) {
+		return true
+	}
+
+	if ge.broadcaster != nil {
+		ge.broadcaster.BroadcastGameUpdate(instance.Session.ID, instance.Session.Multiplier)
+	}
+
+	// Log whole-number milestones (2x, 3x, ...) rather than every tick
+	if math.Floor(instance.Session.Multiplier) > math.Floor(previous) {
+		ge.redisService.publishEvent(models.NewGameEvent(models.EventGameTick, instance.Session))
+	}
+
+	if instance.Session.Multiplier >= instance.Session.CrashPoint {
+		ge.handleCrash(instance)
+		return true
+	}
+	return false
+}
+
+// handleCrash settles a crash game as lost. The caller holds instance.mu.
 func (ge *GameEngine) handleCrash(instance *GameInstance) {
-	instance.Session.Status = "crashed"
-	instance.Session.EndedAt = time.Now()
 	instance.IsRunning = false
+	defer ge.finishGame(instance)
+
+	if err := ge.redisService.TransitionGameSession(instance.Session, models.GameStatusCrashed); err != nil {
+		log.Printf("Crash of game %s rejected: %v", instance.Session.ID, err)
+		return
+	}
 
-	ge.redisService.UpdateGameSession(instance.Session)
 	ge.redisService.CompleteGameSession(instance.Session.UserID, instance.Session.ID)
 
 	if ge.broadcaster != nil {
@@ -302,9 +388,31 @@ func (ge *GameEngine) handleCrash(instance *GameInstance) {
 	)
 
 	ge.recordTransaction(instance.Session, false, 0)
+	ge.publishToFeed(instance.Session, false, 0)
 
-	delete(ge.activeGames, instance.Session.ID)
-	close(instance.StopChan)
+	event := models.NewGameEvent(models.EventGameCrash, instance.Session)
+	event.Multiplier = instance.Session.CrashPoint
+	ge.redisService.publishEvent(event)
+}
+
+// finishGame drops an instance from the in-memory registry and stops its loop.
+func (ge *GameEngine) finishGame(instance *GameInstance) {
+	ge.removeInstance(instance.Session.ID)
+	instance.Stop()
+}
+
+func (ge *GameEngine) removeInstance(gameID string) {
+	ge.gamesMu.Lock()
+	instance, exists := ge.activeGames[gameID]
+	delete(ge.activeGames, gameID)
+	ge.gamesMu.Unlock()
+
+	if exists {
+		instance.Stop()
+		if ge.broadcaster != nil {
+			ge.broadcaster.CloseGame(gameID)
+		}
+	}
 }
 
 func (ge *GameEngine) Cashout(ctx context.Context, userID int64, gameID string) (*models.GameResult, error) {
@@ -313,37 +421,44 @@ func (ge *GameEngine) Cashout(ctx context.Context, userID int64, gameID string)
 		return nil, fmt.Errorf("cashout rate limit exceeded")
 	}
 
-	instance, exists := ge.activeGames[gameID]
+	if err := ge.redisService.CheckCanMoveMoney(userID); err != nil {
+		return nil, err
+	}
+
+	instance, exists := ge.GetActiveGame(gameID)
 	if !exists {
 		session, err := ge.redisService.GetGameSession(gameID)
 		if err != nil {
 			return nil, fmt.Errorf("game not found")
 		}
 
-		if session.Status != "active" {
+		if session.Status != models.GameStatusActive {
 			return nil, fmt.Errorf("game already ended")
 		}
 
 		return nil, fmt.Errorf("game not active")
 	}
 
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
 	if instance.Session.UserID != userID {
 		return nil, fmt.Errorf("unauthorized cashout attempt")
 	}
 
-	if instance.IsRunning {
-		instance.StopChan <- struct{}{}
-		instance.IsRunning = false
+	multiplier := instance.Session.Multiplier
+	instance.Session.CashoutAt = multiplier
+
+	if err := ge.redisService.TransitionGameSession(instance.Session, models.GameStatusCashedOut); err != nil {
+		instance.Session.CashoutAt = 0
+		return nil, fmt.Errorf("cashout rejected: %w", err)
 	}
 
-	winnings := instance.Session.BetAmount * instance.Session.Multiplier
+	instance.IsRunning = false
+	ge.finishGame(instance)
 
-	instance.Session.CashoutAt = instance.Session.Multiplier
-	instance.Session.Status = "cashed_out"
-	instance.Session.EndedAt = time.Now()
-	instance.Session.UpdatedAt = time.Now()
+	winnings := instance.Session.BetAmount * multiplier
 
-	ge.redisService.UpdateGameSession(instance.Session)
 	ge.redisService.CompleteGameSession(userID, gameID)
 
 	err = ge.redisService.ReleaseBalanceFromGame(
@@ -354,28 +469,31 @@ func (ge *GameEngine) Cashout(ctx context.Context, userID int64, gameID string)
 	)
 
 	if err != nil {
-		instance.Session.Status = "active"
-		ge.redisService.UpdateGameSession(instance.Session)
+		log.Printf("Game %s cashed out but payout of %.2f failed: %v", gameID, winnings, err)
 		return nil, fmt.Errorf("failed to process cashout: %v", err)
 	}
 
 	ge.recordTransaction(instance.Session, true, winnings)
+	ge.publishToFeed(instance.Session, true, winnings)
 
-	wallet, _ := ge.redisService.GetWallet(userID)
+	event := models.NewGameEvent(models.EventGameCashout, instance.Session)
+	event.Multiplier = multiplier
+	event.Amount = winnings
+	ge.redisService.publishEvent(event)
+	ge.publishBigWin(instance.Session, winnings)
 
-	delete(ge.activeGames, gameID)
-	close(instance.StopChan)
+	wallet, _ := ge.redisService.GetWallet(userID)
 
 	return &models.GameResult{
 		GameID:     gameID,
 		Win:        true,
-		Multiplier: instance.Session.Multiplier,
+		Multiplier: multiplier,
 		Payout:     winnings,
 		NewBalance: wallet.Balance,
 	}, nil
 }
 
-func (ge *GameEngine) createMinesGame(userID int64, betAmount float64) (*models.GameSession, error) {
+func (ge *GameEngine) createMinesGame(userID int64, betAmount float64, cfg *models.GameConfig) (*models.GameSession, error) {
 	wallet, err := ge.redisService.GetWallet(userID)
 	if err != nil {
 		return nil, err
@@ -392,32 +510,39 @@ func (ge *GameEngine) createMinesGame(userID int64, betAmount float64) (*models.
 		ClientSeed: wallet.ClientSeed,
 		ServerHash: ge.GetServerHash(),
 		Nonce:      wallet.Nonce,
-		Status:     "active",
+		Status:     models.GameStatusActive,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
+	applyGameConfig(session, cfg)
 
-	session.Metadata = map[string]interface{}{
-		"mines":       minePositions,
-		"grid_size":   25,
-		"mine_count":  3,
-		"revealed":    []int{},
-		"multipliers": ge.calculateMineMultipliers(),
+	multipliers := ge.calculateMineMultipliers()
+	for revealed, multiplier := range multipliers {
+		if multiplier > cfg.MaxMultiplier {
+			multipliers[revealed] = cfg.MaxMultiplier
+		}
 	}
 
+	session.Metadata = models.NewMinesMetadata(&models.MinesState{
+		Mines:       minePositions,
+		GridSize:    25,
+		MineCount:   3,
+		Revealed:    []int{},
+		Multipliers: multipliers,
+	})
+
 	if err := ge.redisService.SaveGameSession(session); err != nil {
 		return nil, err
 	}
 
-	wallet.Nonce++
-	ge.redisService.SaveWallet(wallet)
+	ge.redisService.IncrementWalletNonce(userID)
 
 	return session, nil
 }
 
 func (ge *GameEngine) generateMinePositions(clientSeed string, nonce int64) []int {
 	message := fmt.Sprintf("mines:%s:%d", clientSeed, nonce)
-	h := hmac.New(sha256.New, []byte(ge.serverSeed))
+	h := hmac.New(sha256.New, []byte(ge.GetServerSeed()))
 	h.Write([]byte(message))
 	hash := hex.EncodeToString(h.Sum(nil))
 
@@ -462,7 +587,169 @@ func (ge *GameEngine) runMinesGame(instance *GameInstance) {
 	// Game state managed through API calls
 }
 
-func (ge *GameEngine) createDiceGame(userID int64, betAmount float64) (*models.GameSession, error) {
+// RevealMine uncovers one tile. Hitting a mine settles the game as lost.
+func (ge *GameEngine) RevealMine(ctx context.Context, userID int64, gameID string, position int) (*models.MinesRevealResponse, error) {
+	if err := ge.redisService.CheckCanMoveMoney(userID); err != nil {
+		return nil, err
+	}
+
+	unlock := ge.lockGame(gameID)
+	defer unlock()
+
+	session, state, err := ge.getActiveMinesGame(userID, gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	if state.IsRevealed(position) {
+		return nil, fmt.Errorf("position already revealed")
+	}
+
+	isMine := state.IsMine(position)
+	state.Revealed = append(state.Revealed, position)
+	multiplier := state.CurrentMultiplier()
+
+	if isMine {
+		if err := ge.redisService.TransitionGameSession(session, models.GameStatusLost); err != nil {
+			return nil, fmt.Errorf("failed to settle game: %w", err)
+		}
+
+		ge.redisService.CompleteGameSession(userID, gameID)
+		ge.redisService.ReleaseBalanceFromGame(userID, session.BetAmount, false, 0)
+		ge.recordTransaction(session, false, 0)
+		ge.publishToFeed(session, false, 0)
+		ge.removeInstance(gameID)
+	} else {
+		session.Multiplier = multiplier
+		if err := ge.redisService.UpdateGameSession(session); err != nil {
+			return nil, fmt.Errorf("failed to save reveal: %w", err)
+		}
+	}
+
+	event := models.NewGameEvent(models.EventGameReveal, session)
+	event.Position = &position
+	event.IsMine = isMine
+	ge.redisService.publishEvent(event)
+
+	if isMine {
+		ge.redisService.publishEvent(models.NewGameEvent(models.EventGameSettled, session))
+	}
+
+	response := &models.MinesRevealResponse{
+		GameID:     gameID,
+		Position:   position,
+		IsMine:     isMine,
+		Multiplier: multiplier,
+		Positions:  state.Revealed,
+		MineCount:  len(state.Mines),
+		GameOver:   isMine,
+		Status:     session.Status,
+	}
+	if isMine {
+		response.MinePositions = state.Mines
+	}
+
+	return response, nil
+}
+
+// CashoutMines settles a mines game at the multiplier for the tiles revealed.
+func (ge *GameEngine) CashoutMines(ctx context.Context, userID int64, gameID string) (*models.MinesCashoutResponse, error) {
+	if err := ge.redisService.CheckCanMoveMoney(userID); err != nil {
+		return nil, err
+	}
+
+	unlock := ge.lockGame(gameID)
+	defer unlock()
+
+	session, state, err := ge.getActiveMinesGame(userID, gameID)
+	if err != nil {
+		return nil, err
+	}
+
+	revealedCount := len(state.Revealed)
+	multiplier := state.CurrentMultiplier()
+	winnings := session.BetAmount * multiplier
+
+	session.CashoutAt = multiplier
+	session.Multiplier = multiplier
+
+	if err := ge.redisService.TransitionGameSession(session, models.GameStatusCashedOut); err != nil {
+		return nil, fmt.Errorf("failed to process cashout: %w", err)
+	}
+
+	ge.removeInstance(gameID)
+
+	if err := ge.redisService.ReleaseBalanceFromGame(userID, session.BetAmount, true, winnings); err != nil {
+		log.Printf("Game %s cashed out but payout of %.2f failed: %v", gameID, winnings, err)
+		return nil, fmt.Errorf("failed to process cashout: %v", err)
+	}
+
+	ge.redisService.CompleteGameSession(userID, gameID)
+	ge.recordTransaction(session, true, winnings)
+	ge.publishToFeed(session, true, winnings)
+
+	event := models.NewGameEvent(models.EventGameCashout, session)
+	event.Amount = winnings
+	ge.redisService.publishEvent(event)
+	ge.publishBigWin(session, winnings)
+
+	wallet, err := ge.redisService.GetWallet(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet: %v", err)
+	}
+
+	return &models.MinesCashoutResponse{
+		GameResult: models.GameResult{
+			GameID:     gameID,
+			Win:        true,
+			Multiplier: multiplier,
+			Payout:     winnings,
+			NewBalance: wallet.Balance,
+		},
+		BetAmount:     session.BetAmount,
+		RevealedCount: revealedCount,
+		Status:        session.Status,
+	}, nil
+}
+
+// lockGame takes instance.mu of a game this process runs, so turn-based
+// load, change and save steps do not interleave, and marks the game as
+// played so the stale game sweep leaves it alone. Games run by another
+// process are covered by the revision check in UpdateGameSession.
+func (ge *GameEngine) lockGame(gameID string) func() {
+	instance, exists := ge.GetActiveGame(gameID)
+	if !exists {
+		return func() {}
+	}
+	instance.mu.Lock()
+	instance.LastUpdate = time.Now()
+	return instance.mu.Unlock
+}
+
+// getActiveMinesGame loads a mines game from Redis, which is the source of
+// truth for turn-based games, and checks it can still be played.
+func (ge *GameEngine) getActiveMinesGame(userID int64, gameID string) (*models.GameSession, *models.MinesState, error) {
+	session, err := ge.redisService.GetGameSession(gameID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrGameNotFound, err)
+	}
+
+	if session.UserID != userID {
+		return nil, nil, ErrNotGameOwner
+	}
+
+	if session.Status != models.GameStatusActive {
+		return nil, nil, fmt.Errorf("%w: %s", ErrGameNotActive, session.Status)
+	}
+
+	if session.Metadata == nil || session.Metadata.Mines == nil {
+		return nil, nil, fmt.Errorf("mine data missing")
+	}
+
+	return session, session.Metadata.Mines, nil
+}
+
+func (ge *GameEngine) createDiceGame(userID int64, betAmount float64, cfg *models.GameConfig) (*models.GameSession, error) {
 	wallet, err := ge.redisService.GetWallet(userID)
 	if err != nil {
 		return nil, err
@@ -479,30 +766,30 @@ func (ge *GameEngine) createDiceGame(userID int64, betAmount float64) (*models.G
 		ClientSeed: wallet.ClientSeed,
 		ServerHash: ge.GetServerHash(),
 		Nonce:      wallet.Nonce,
-		Status:     "active",
+		Status:     models.GameStatusActive,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
+	applyGameConfig(session, cfg)
 
-	session.Metadata = map[string]interface{}{
-		"roll":    roll,
-		"target":  50, // Default target (under 50 wins)
-		"is_over": false,
-	}
+	session.Metadata = models.NewDiceMetadata(&models.DiceState{
+		Roll:   roll,
+		Target: 50, // Default target (under 50 wins)
+		Over:   false,
+	})
 
 	if err := ge.redisService.SaveGameSession(session); err != nil {
 		return nil, err
 	}
 
-	wallet.Nonce++
-	ge.redisService.SaveWallet(wallet)
+	ge.redisService.IncrementWalletNonce(userID)
 
 	return session, nil
 }
 
 func (ge *GameEngine) generateDiceRoll(clientSeed string, nonce int64) int {
 	message := fmt.Sprintf("dice:%s:%d", clientSeed, nonce)
-	h := hmac.New(sha256.New, []byte(ge.serverSeed))
+	h := hmac.New(sha256.New, []byte(ge.GetServerSeed()))
 	h.Write([]byte(message))
 	hash := hex.EncodeToString(h.Sum(nil))
 
@@ -511,14 +798,18 @@ func (ge *GameEngine) generateDiceRoll(clientSeed string, nonce int64) int {
 }
 
 func (ge *GameEngine) PlayDice(ctx context.Context, userID int64, gameID string, target int, over bool) (*models.DicePlayResponse, error) {
-	instance, exists := ge.activeGames[gameID]
+	if err := ge.redisService.CheckCanMoveMoney(userID); err != nil {
+		return nil, err
+	}
+
+	instance, exists := ge.GetActiveGame(gameID)
 	if !exists {
 		// Check if it's in Redis but not active (already played)
 		session, err := ge.redisService.GetGameSession(gameID)
 		if err != nil {
 			return nil, fmt.Errorf("game not found")
 		}
-		if session.Status != "active" {
+		if session.Status != models.GameStatusActive {
 			return nil, fmt.Errorf("game already completed")
 		}
 		// If active in Redis but not in memory, it might be a restart or error.
@@ -526,38 +817,20 @@ func (ge *GameEngine) PlayDice(ctx context.Context, userID int64, gameID string,
 		return nil, fmt.Errorf("game session lost")
 	}
 
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
 	if instance.Session.UserID != userID {
 		return nil, fmt.Errorf("unauthorized")
 	}
 
-	// Stop the timeout timer
-	select {
-	case instance.StopChan <- struct{}{}:
-	default:
-	}
-
 	session := instance.Session
-	metadata := session.Metadata
-	log.Println(metadata)
-
-	rollRaw, ok := metadata["roll"]
-	if !ok {
+	if session.Metadata == nil || session.Metadata.Dice == nil {
 		return nil, fmt.Errorf("roll data missing")
 	}
 
-	var roll int
-	switch v := rollRaw.(type) {
-	case float64:
-		roll = int(v)
-	case int:
-		roll = v
-	case int64:
-		roll = int(v)
-	case uint64:
-		roll = int(v)
-	default:
-		return nil, fmt.Errorf("unexpected roll type: %T", rollRaw)
-	}
+	state := session.Metadata.Dice
+	roll := state.Roll
 
 	win := false
 	if over {
@@ -573,9 +846,16 @@ func (ge *GameEngine) PlayDice(ctx context.Context, userID int64, gameID string,
 		probability = float64(target) / 100.0
 	}
 
-	multiplier := (0.99 / probability)
-	if multiplier > 9900.0 {
-		multiplier = 9900.0
+	// Sessions from before runtime config carry no values of their own
+	houseEdge, maxMultiplier := session.HouseEdge, session.MaxMultiplier
+	if maxMultiplier == 0 {
+		defaults := models.DefaultGameConfig(models.GameTypeDice)
+		houseEdge, maxMultiplier = defaults.HouseEdge, defaults.MaxMultiplier
+	}
+
+	multiplier := ((1 - houseEdge) / probability)
+	if multiplier > maxMultiplier {
+		multiplier = maxMultiplier
 	}
 
 	payout := 0.0
@@ -583,17 +863,25 @@ func (ge *GameEngine) PlayDice(ctx context.Context, userID int64, gameID string,
 		payout = session.BetAmount * multiplier
 	}
 
-	session.Status = "completed"
 	session.Multiplier = multiplier
 	session.CashoutAt = multiplier
-	session.EndedAt = time.Now()
 
-	metadata["played"] = true
-	metadata["target"] = target
-	metadata["over"] = over
-	metadata["win"] = win
-	metadata["payout"] = payout
-	session.Metadata = metadata
+	state.Played = true
+	state.Target = target
+	state.Over = over
+	state.Win = win
+	state.Payout = payout
+
+	status := models.GameStatusLost
+	if win {
+		status = models.GameStatusWon
+	}
+	if err := ge.redisService.TransitionGameSession(session, status); err != nil {
+		return nil, fmt.Errorf("dice play rejected: %w", err)
+	}
+
+	// Stop the timeout timer
+	ge.finishGame(instance)
 
 	if win {
 		ge.redisService.ReleaseBalanceFromGame(userID, session.BetAmount, true, payout)
@@ -601,11 +889,16 @@ func (ge *GameEngine) PlayDice(ctx context.Context, userID int64, gameID string,
 		ge.redisService.ReleaseBalanceFromGame(userID, session.BetAmount, false, 0)
 	}
 
-	ge.redisService.UpdateGameSession(session)
 	ge.redisService.CompleteGameSession(userID, gameID)
 	ge.recordTransaction(session, win, payout)
+	ge.publishToFeed(session, win, payout)
 
-	delete(ge.activeGames, gameID)
+	event := models.NewGameEvent(models.EventGameSettled, session)
+	event.Amount = payout
+	ge.redisService.publishEvent(event)
+	if win {
+		ge.publishBigWin(session, payout)
+	}
 
 	log.Println(&models.DicePlayResponse{
 		GameID:     gameID,
@@ -633,23 +926,13 @@ func (ge *GameEngine) runDiceGame(instance *GameInstance) {
 
 	select {
 	case <-timer.C:
-		// Timeout - Refund
-		ge.redisService.ReleaseBalanceFromGame(
-			instance.Session.UserID,
-			instance.Session.BetAmount,
-			true, // Treat as win to refund
-			0,    // 0 winnings means just return bet?
-			// Wait, ReleaseBalanceFromGame(..., won, winnings)
-			// If won=true, it adds betAmount + winnings.
-			// So winnings=0 means adds betAmount. Correct.
-		)
-
-		instance.Session.Status = "refunded"
-		instance.Session.EndedAt = time.Now()
-		ge.redisService.UpdateGameSession(instance.Session)
-		ge.redisService.CompleteGameSession(instance.Session.UserID, instance.Session.ID)
-
-		delete(ge.activeGames, instance.Session.ID)
+		instance.mu.Lock()
+		defer instance.mu.Unlock()
+
+		// Timeout - Refund, unless PlayDice settled it in the meantime
+		if err := ge.refundGame(instance); err != nil {
+			log.Printf("Refund of game %s rejected: %v", instance.Session.ID, err)
+		}
 
 	case <-instance.StopChan:
 		// Game played, do nothing (handled in PlayDice)
@@ -657,6 +940,9 @@ func (ge *GameEngine) runDiceGame(instance *GameInstance) {
 }
 
 func (ge *GameEngine) GetActiveGame(gameID string) (*GameInstance, bool) {
+	ge.gamesMu.RLock()
+	defer ge.gamesMu.RUnlock()
+
 	instance, exists := ge.activeGames[gameID]
 	return instance, exists
 }
@@ -670,7 +956,7 @@ func (ge *GameEngine) GetUserActiveGames(userID int64) ([]*models.GameSession, e
 	var sessions []*models.GameSession
 	for _, gameID := range gameIDs {
 		session, err := ge.redisService.GetGameSession(gameID)
-		if err == nil && session.Status == "active" {
+		if err == nil && session.Status == models.GameStatusActive {
 			sessions = append(sessions, session)
 		}
 	}
@@ -678,16 +964,102 @@ func (ge *GameEngine) GetUserActiveGames(userID int64) ([]*models.GameSession, e
 	return sessions, nil
 }
 
+// RefundUserGames refunds every game the player has in play. It is used when
+// an account is restricted, since the player can no longer cash out and a
+// running round would otherwise take the stake. Games that settle on their
+// own in the meantime are skipped.
+func (ge *GameEngine) RefundUserGames(userID int64) (int, error) {
+	sessions, err := ge.GetUserActiveGames(userID)
+	if err != nil {
+		return 0, err
+	}
+
+	refunded := 0
+	for _, session := range sessions {
+		err := ge.ForceSettle(session.ID, models.OutcomeRefund)
+		if errors.Is(err, models.ErrInvalidTransition) || errors.Is(err, ErrGameNotActive) || err == ErrGameNotFound {
+			continue
+		}
+		if err != nil {
+			return refunded, fmt.Errorf("failed to refund game %s: %v", session.ID, err)
+		}
+		refunded++
+	}
+	return refunded, nil
+}
+
 func (ge *GameEngine) ForceCrash(gameID string) error {
-	instance, exists := ge.activeGames[gameID]
+	return ge.ForceSettle(gameID, models.OutcomeCrash)
+}
+
+// ForceSettle ends a stuck game as lost (crash) or by returning the stake
+// (refund). It also works for games that are active in Redis but no longer
+// running in this process, e.g. after a restart.
+func (ge *GameEngine) ForceSettle(gameID string, outcome models.GameOutcome) error {
+	instance, exists := ge.GetActiveGame(gameID)
 	if !exists {
-		return fmt.Errorf("game not active")
+		session, err := ge.redisService.GetGameSession(gameID)
+		if err != nil {
+			return ErrGameNotFound
+		}
+		if session.Status != models.GameStatusActive {
+			return fmt.Errorf("%w: %s", ErrGameNotActive, session.Status)
+		}
+		instance = &GameInstance{Session: session, StopChan: make(chan struct{})}
 	}
 
-	ge.handleCrash(instance)
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	switch outcome {
+	case models.OutcomeCrash:
+		if instance.Session.GameType != models.GameTypeCrash {
+			return fmt.Errorf("only crash games can be force-crashed, refund instead")
+		}
+		ge.handleCrash(instance)
+		if instance.Session.Status != models.GameStatusCrashed {
+			return fmt.Errorf("%w: %s", ErrGameNotActive, instance.Session.Status)
+		}
+	case models.OutcomeRefund:
+		return ge.refundGame(instance)
+	default:
+		return fmt.Errorf("unknown outcome: %s", outcome)
+	}
 	return nil
 }
 
+// refundGame returns the stake of an unsettled game. The caller holds
+// instance.mu.
+func (ge *GameEngine) refundGame(instance *GameInstance) error {
+	defer ge.finishGame(instance)
+
+	session := instance.Session
+	if err := ge.redisService.TransitionGameSession(session, models.GameStatusRefunded); err != nil {
+		return fmt.Errorf("refund rejected: %w", err)
+	}
+
+	wallet, err := ge.redisService.RefundBalanceFromGame(session.UserID, session.BetAmount, session.ID)
+	if err != nil && wallet == nil {
+		log.Printf("Game %s refunded but returning %.2f failed: %v", session.ID, session.BetAmount, err)
+		return fmt.Errorf("failed to return stake: %v", err)
+	}
+	if err != nil {
+		log.Printf("Game %s refunded but transaction not recorded: %v", session.ID, err)
+	}
+
+	ge.redisService.CompleteGameSession(session.UserID, session.ID)
+	ge.redisService.publishEvent(models.NewGameEvent(models.EventGameRefund, session))
+	return nil
+}
+
+// applyGameConfig records the configuration a bet was placed under, so
+// payouts and verification use it even if operators change it mid-game.
+func applyGameConfig(session *models.GameSession, cfg *models.GameConfig) {
+	session.HouseEdge = cfg.HouseEdge
+	session.MaxMultiplier = cfg.MaxMultiplier
+	session.ConfigVersion = cfg.Version
+}
+
 func (ge *GameEngine) recordTransaction(session *models.GameSession, won bool, payout float64) error {
 	txType := models.TransactionTypeBet
 	description := fmt.Sprintf("Placed bet on %s", session.GameType)
@@ -718,14 +1090,116 @@ func (ge *GameEngine) recordTransaction(session *models.GameSession, won bool, p
 	return ge.redisService.SaveTransaction(tx)
 }
 
+// publishToFeed shows a settled bet on the public feeds unless the player
+// has opted out.
+func (ge *GameEngine) publishToFeed(session *models.GameSession, won bool, payout float64) {
+	hidden, err := ge.redisService.IsFeedOptOut(session.UserID)
+	if err != nil || hidden {
+		return
+	}
+
+	var name string
+	if user, err := ge.redisService.GetUser(session.UserID); err == nil {
+		name = user.Username
+		if name == "" {
+			name = user.FirstName
+		}
+	}
+
+	entry := &models.FeedEntry{
+		Player:    models.MaskUsername(name),
+		GameType:  session.GameType,
+		BetAmount: session.BetAmount,
+		Payout:    payout,
+		Win:       won,
+		CreatedAt: time.Now(),
+	}
+	if won && session.BetAmount > 0 {
+		entry.Multiplier = payout / session.BetAmount
+	}
+
+	if err := ge.redisService.AddFeedEntry(entry); err != nil {
+		log.Printf("Feed: %v", err)
+	}
+	if ge.broadcaster != nil {
+		ge.broadcaster.BroadcastFeed(entry)
+	}
+}
+
+// publishBigWin flags wins large enough for CRM / affiliate follow-up.
+func (ge *GameEngine) publishBigWin(session *models.GameSession, payout float64) {
+	if session.CashoutAt < models.BigWinMultiplier && payout < models.BigWinPayout {
+		return
+	}
+
+	event := models.NewGameEvent(models.EventBigWin, session)
+	event.Multiplier = session.CashoutAt
+	event.Amount = payout
+	ge.redisService.publishEvent(event)
+}
+
+// CleanupStaleGames settles games nothing has touched for maxAge. A crash
+// game that stopped ticking is crashed, as ForceSettle would; any other game
+// is refunded, since how it ends is up to the player. Each one is written to
+// the audit log.
 func (ge *GameEngine) CleanupStaleGames(maxAge time.Duration) {
+	ge.gamesMu.RLock()
+	instances := make([]*GameInstance, 0, len(ge.activeGames))
 	for _, instance := range ge.activeGames {
-		if time.Since(instance.LastUpdate) > maxAge {
-			ge.handleCrash(instance)
+		instances = append(instances, instance)
+	}
+	ge.gamesMu.RUnlock()
+
+	for _, instance := range instances {
+		ge.expireIfStale(instance, maxAge)
+	}
+}
+
+func (ge *GameEngine) expireIfStale(instance *GameInstance, maxAge time.Duration) {
+	instance.mu.Lock()
+	defer instance.mu.Unlock()
+
+	session := instance.Session
+	if session.Status != models.GameStatusActive || time.Since(instance.LastUpdate) <= maxAge {
+		return
+	}
+
+	outcome := models.OutcomeRefund
+	if session.GameType == models.GameTypeCrash {
+		outcome = models.OutcomeCrash
+		ge.handleCrash(instance)
+		if session.Status != models.GameStatusCrashed {
+			return
 		}
+	} else if err := ge.refundGame(instance); err != nil {
+		log.Printf("Stale game %s not refunded: %v", session.ID, err)
+		return
+	}
+
+	if err := ge.redisService.WriteAuditEntry(&models.AuditEntry{
+		Action:       models.AuditExpireGame,
+		TargetUserID: session.UserID,
+		TargetGameID: session.ID,
+		Reason:       fmt.Sprintf("idle for over %s", maxAge),
+		Details: map[string]interface{}{
+			"game_type": session.GameType,
+			"outcome":   outcome,
+		},
+	}); err != nil {
+		log.Printf("Stale game %s settled but not audited: %v", session.ID, err)
 	}
 }
 
-func (ge *GameEngine) RotateServerSeed(newSeed string) {
+// RotateServerSeed switches to a new seed for games started from now on.
+// An empty seed generates a random one. It returns the new seed's hash.
+func (ge *GameEngine) RotateServerSeed(newSeed string) string {
+	if newSeed == "" {
+		newSeed = generateServerSeed()
+	}
+
+	ge.seedMu.Lock()
 	ge.serverSeed = newSeed
+	ge.seedMu.Unlock()
+
+	return HashServerSeed(newSeed)
 }
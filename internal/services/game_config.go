@@ -0,0 +1,124 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+
+	"sample-miniapp-backend/internal/models"
+)
+
+var ErrGameConfigConflict = errors.New("game config was changed by someone else")
+
+// GetGameConfig returns the live configuration for a game type, or the
+// built-in defaults if it has never been changed.
+func (s *RedisService) GetGameConfig(gameType models.GameType) (*models.GameConfig, error) {
+	data, err := s.client.Get(s.ctx, fmt.Sprintf(KeyGameConfig, gameType)).Result()
+	if err == redis.Nil {
+		return models.DefaultGameConfig(gameType), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game config: %v", err)
+	}
+
+	var cfg models.GameConfig
+	if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal game config: %v", err)
+	}
+	return &cfg, nil
+}
+
+func (s *RedisService) ListGameConfigs() ([]*models.GameConfig, error) {
+	configs := make([]*models.GameConfig, 0, len(models.ConfigurableGameTypes))
+	for _, gameType := range models.ConfigurableGameTypes {
+		cfg, err := s.GetGameConfig(gameType)
+		if err != nil {
+			return nil, err
+		}
+		configs = append(configs, cfg)
+	}
+	return configs, nil
+}
+
+// UpdateGameConfig applies an update as a new version and appends that
+// version to the game's change history.
+func (s *RedisService) UpdateGameConfig(gameType models.GameType, update *models.GameConfigUpdate, actorID int64) (*models.GameConfig, error) {
+	key := fmt.Sprintf(KeyGameConfig, gameType)
+	historyKey := fmt.Sprintf(KeyGameConfigHistory, gameType)
+
+	for i := 0; i < 3; i++ {
+		var cfg *models.GameConfig
+
+		err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(s.ctx, key).Result()
+			switch {
+			case err == redis.Nil:
+				cfg = models.DefaultGameConfig(gameType)
+			case err != nil:
+				return err
+			default:
+				cfg = &models.GameConfig{}
+				if err := json.Unmarshal([]byte(data), cfg); err != nil {
+					return fmt.Errorf("failed to unmarshal game config: %v", err)
+				}
+			}
+
+			if update.ExpectedVersion != nil && *update.ExpectedVersion != cfg.Version {
+				return fmt.Errorf("%w: now at version %d", ErrGameConfigConflict, cfg.Version)
+			}
+
+			update.Apply(cfg)
+			if err := cfg.Validate(); err != nil {
+				return err
+			}
+			cfg.Version++
+			cfg.UpdatedBy = actorID
+			cfg.UpdatedAt = time.Now()
+			cfg.Reason = update.Reason
+
+			updated, err := json.Marshal(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to marshal game config: %v", err)
+			}
+
+			_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(s.ctx, key, updated, 0)
+				pipe.LPush(s.ctx, historyKey, updated)
+				pipe.LTrim(s.ctx, historyKey, 0, GameConfigHistoryLen-1)
+				return nil
+			})
+			return err
+		}, key)
+
+		if err == nil {
+			return cfg, nil
+		}
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("failed to update game config: transaction conflict")
+}
+
+// GameConfigHistory returns past versions, newest first.
+func (s *RedisService) GameConfigHistory(gameType models.GameType, limit int64) ([]*models.GameConfig, error) {
+	values, err := s.client.LRange(s.ctx, fmt.Sprintf(KeyGameConfigHistory, gameType), 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get game config history: %v", err)
+	}
+
+	history := make([]*models.GameConfig, 0, len(values))
+	for _, data := range values {
+		var cfg models.GameConfig
+		if err := json.Unmarshal([]byte(data), &cfg); err != nil {
+			continue
+		}
+		history = append(history, &cfg)
+	}
+	return history, nil
+}
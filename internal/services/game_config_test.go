@@ -0,0 +1,67 @@
+package services_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestGameConfigVersioning(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	// A throwaway game type so the test never touches live configuration
+	gameType := models.GameType(fmt.Sprintf("test-%d", time.Now().UnixNano()))
+
+	current, err := redisService.GetGameConfig(gameType)
+	if err != nil || current.Version != 0 || !current.Enabled {
+		t.Fatalf("Expected enabled defaults at version 0, got %+v (%v)", current, err)
+	}
+
+	disabled := false
+	expected := int64(0)
+	updated, err := redisService.UpdateGameConfig(gameType, &models.GameConfigUpdate{
+		Enabled:         &disabled,
+		ExpectedVersion: &expected,
+		Reason:          "incident",
+	}, 1)
+	if err != nil {
+		t.Fatalf("Failed to update config: %v", err)
+	}
+	if updated.Version != 1 || updated.Enabled {
+		t.Errorf("Expected disabled config at version 1, got %+v", updated)
+	}
+
+	// A second writer still expecting version 0 must not overwrite it
+	_, err = redisService.UpdateGameConfig(gameType, &models.GameConfigUpdate{
+		ExpectedVersion: &expected,
+		Reason:          "stale",
+	}, 2)
+	if !errors.Is(err, services.ErrGameConfigConflict) {
+		t.Errorf("Expected a version conflict, got %v", err)
+	}
+
+	edge := 0.9
+	if _, err := redisService.UpdateGameConfig(gameType, &models.GameConfigUpdate{HouseEdge: &edge, Reason: "bad"}, 1); err == nil {
+		t.Error("Expected an invalid house edge to be rejected")
+	}
+
+	history, err := redisService.GameConfigHistory(gameType, 10)
+	if err != nil || len(history) != 1 || history[0].Reason != "incident" {
+		t.Errorf("Expected one history entry, got %+v (%v)", history, err)
+	}
+}
@@ -2,8 +2,10 @@ package services_test
 
 import (
 	"context"
+	"errors"
 	"log"
 	"math"
+	"sync"
 	"testing"
 	"time"
 
@@ -92,3 +94,179 @@ func cleanupTestData(t *testing.T, redisService *services.RedisService, userID i
 		t.Errorf("Failed to cleanup game data: %v", err)
 	}
 }
+
+func TestRefundUserGames(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+	gameEngine := services.NewGameEngine(redisService)
+
+	userID := time.Now().UnixNano() % 1000000000
+	defer redisService.DeleteWallet(userID)
+
+	start, err := redisService.GetWallet(userID)
+	if err != nil {
+		t.Fatalf("Failed to get wallet: %v", err)
+	}
+
+	session, err := gameEngine.PlaceBet(context.Background(), userID, &models.BetRequest{
+		GameType: models.GameTypeMines,
+		Amount:   1000,
+	})
+	if err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+	defer cleanupTestData(t, redisService, userID, session.ID)
+
+	if err := redisService.SetAccountState(&models.AccountState{UserID: userID, Status: models.AccountFrozen, Reason: "test"}); err != nil {
+		t.Fatalf("Failed to freeze account: %v", err)
+	}
+	if _, err := gameEngine.RevealMine(context.Background(), userID, session.ID, 0); !errors.Is(err, services.ErrAccountRestricted) {
+		t.Errorf("Expected a frozen account to be refused a reveal, got %v", err)
+	}
+
+	refunded, err := gameEngine.RefundUserGames(userID)
+	if err != nil || refunded != 1 {
+		t.Fatalf("Expected one game refunded, got %d (%v)", refunded, err)
+	}
+
+	stored, err := redisService.GetGameSession(session.ID)
+	if err != nil || stored.Status != models.GameStatusRefunded {
+		t.Errorf("Expected the game to be refunded, got %+v (%v)", stored, err)
+	}
+	wallet, err := redisService.GetWallet(userID)
+	if err != nil || wallet.Balance != start.Balance || wallet.LockedBalance != 0 {
+		t.Errorf("Expected the stake back, got %+v (%v)", wallet, err)
+	}
+}
+
+func TestConcurrentMineReveals(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+	gameEngine := services.NewGameEngine(redisService)
+
+	userID := time.Now().UnixNano() % 1000000000
+	defer redisService.DeleteWallet(userID)
+
+	session, err := gameEngine.PlaceBet(context.Background(), userID, &models.BetRequest{
+		GameType: models.GameTypeMines,
+		Amount:   1000,
+	})
+	if err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+	defer cleanupTestData(t, redisService, userID, session.ID)
+	defer gameEngine.ForceSettle(session.ID, models.OutcomeRefund)
+
+	var safe []int
+	state := session.Metadata.Mines
+	for position := 0; position < state.GridSize && len(safe) < 4; position++ {
+		if !state.IsMine(position) {
+			safe = append(safe, position)
+		}
+	}
+
+	var wg sync.WaitGroup
+	revealed := make(chan int, len(safe))
+	for _, position := range safe {
+		wg.Add(1)
+		go func(position int) {
+			defer wg.Done()
+			if _, err := gameEngine.RevealMine(context.Background(), userID, session.ID, position); err == nil {
+				revealed <- position
+			}
+		}(position)
+	}
+	wg.Wait()
+	close(revealed)
+
+	stored, err := redisService.GetGameSession(session.ID)
+	if err != nil {
+		t.Fatalf("Failed to get game session: %v", err)
+	}
+	count := 0
+	for position := range revealed {
+		count++
+		if !stored.Metadata.Mines.IsRevealed(position) {
+			t.Errorf("Reveal of %d reported safe but is missing from the stored game", position)
+		}
+	}
+	if count != len(safe) {
+		t.Errorf("Expected all %d reveals to succeed in turn, got %d", len(safe), count)
+	}
+
+	// A copy read before another write is refused rather than saved over it
+	first, _ := redisService.GetGameSession(session.ID)
+	second, _ := redisService.GetGameSession(session.ID)
+	if err := redisService.UpdateGameSession(first); err != nil {
+		t.Fatalf("Failed to update game session: %v", err)
+	}
+	if err := redisService.UpdateGameSession(second); !errors.Is(err, services.ErrGameChanged) {
+		t.Errorf("Expected a stale update to fail with ErrGameChanged, got %v", err)
+	}
+}
+
+func TestCleanupStaleGamesRefundsMines(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+	gameEngine := services.NewGameEngine(redisService)
+
+	userID := time.Now().UnixNano() % 1000000000
+	defer redisService.DeleteWallet(userID)
+
+	start, err := redisService.GetWallet(userID)
+	if err != nil {
+		t.Fatalf("Failed to get wallet: %v", err)
+	}
+
+	session, err := gameEngine.PlaceBet(context.Background(), userID, &models.BetRequest{
+		GameType: models.GameTypeMines,
+		Amount:   1000,
+	})
+	if err != nil {
+		t.Fatalf("Failed to place bet: %v", err)
+	}
+	defer cleanupTestData(t, redisService, userID, session.ID)
+
+	gameEngine.CleanupStaleGames(0)
+
+	stored, err := redisService.GetGameSession(session.ID)
+	if err != nil || stored.Status != models.GameStatusRefunded {
+		t.Fatalf("Expected an idle mines game to be refunded, got %+v (%v)", stored, err)
+	}
+	wallet, err := redisService.GetWallet(userID)
+	if err != nil || wallet.Balance != start.Balance || wallet.TotalWon != start.TotalWon {
+		t.Errorf("Expected the stake back without a win, got %+v (%v)", wallet, err)
+	}
+
+	entries, err := redisService.ReadAuditLog(userID, "", 10)
+	if err != nil || len(entries) == 0 || entries[0].Action != models.AuditExpireGame || entries[0].TargetGameID != session.ID {
+		t.Errorf("Expected an expire_game audit entry, got %+v (%v)", entries, err)
+	}
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const idempotencyPending = "pending"
+
+var ErrRequestInProgress = errors.New("request is already being processed")
+
+// BeginIdempotentRequest claims a client request ID. fresh is true when the
+// caller should run the request; otherwise response holds the stored reply
+// of the earlier run.
+func (s *RedisService) BeginIdempotentRequest(userID int64, requestID string) (response []byte, fresh bool, err error) {
+	key := fmt.Sprintf(KeyIdempotency, userID, requestID)
+
+	claimed, err := s.client.SetNX(s.ctx, key, idempotencyPending, TTLIdempotency).Result()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to claim request id: %v", err)
+	}
+	if claimed {
+		return nil, true, nil
+	}
+
+	data, err := s.client.Get(s.ctx, key).Bytes()
+	if err == redis.Nil {
+		// Expired between the two calls; let the caller try again
+		return nil, false, ErrRequestInProgress
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read request id: %v", err)
+	}
+	if string(data) == idempotencyPending {
+		return nil, false, ErrRequestInProgress
+	}
+
+	return data, false, nil
+}
+
+// CompleteIdempotentRequest stores the reply that retries of the request get.
+func (s *RedisService) CompleteIdempotentRequest(userID int64, requestID string, response []byte) error {
+	key := fmt.Sprintf(KeyIdempotency, userID, requestID)
+	if err := s.client.Set(s.ctx, key, response, TTLIdempotency).Err(); err != nil {
+		return fmt.Errorf("failed to store request reply: %v", err)
+	}
+	return nil
+}
+
+// AbandonIdempotentRequest releases a request ID whose run failed, so the
+// client may retry it.
+func (s *RedisService) AbandonIdempotentRequest(userID int64, requestID string) {
+	s.client.Del(s.ctx, fmt.Sprintf(KeyIdempotency, userID, requestID))
+}
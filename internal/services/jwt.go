@@ -1,47 +1,163 @@
 package services
 
 import (
+	"context"
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
 	"fmt"
+	"log"
+	"math/big"
+	"sync"
 	"time"
 
 	"sample-miniapp-backend/internal/config"
 	"sample-miniapp-backend/internal/models"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+const (
+	JWTAlgorithmHS256 = "HS256"
+	JWTAlgorithmRS256 = "RS256"
+	JWTAlgorithmEdDSA = "EdDSA"
+
+	jwtKeyReloadInterval = time.Minute
+	jwtKeyMissReload     = 10 * time.Second // throttle reloads for unknown kids
+)
+
+type signingKey struct {
+	id        string
+	algorithm string
+	createdAt time.Time
+	private   crypto.Signer
+}
+
+// JWTService issues and verifies access tokens. With HS256 it uses the
+// shared secret. With RS256 or EdDSA it signs with the newest of a set of
+// keys shared through Redis, names the key in the kid header, and publishes
+// the public halves as a JWKS so other services can verify tokens. Private
+// keys are sealed with keyCipher before they reach Redis.
 type JWTService struct {
-	secret string
-	expiry time.Duration
+	secret        string
+	expiry        time.Duration
+	refreshExpiry time.Duration
+	algorithm     string
+	rotateEvery   time.Duration
+	issuer        string
+	audience      string
+	keyCipher     cipher.AEAD
+	redisService  *RedisService
+
+	mu         sync.RWMutex
+	keys       map[string]*signingKey
+	current    *signingKey
+	lastReload time.Time
 }
 
-func NewJWTService(cfg *config.Config) *JWTService {
-	return &JWTService{
-		secret: cfg.JWTSecret,
-		expiry: cfg.JWTExpiry,
+func NewJWTService(cfg *config.Config, redisService *RedisService) (*JWTService, error) {
+	s := &JWTService{
+		secret:        cfg.JWTSecret,
+		expiry:        cfg.JWTExpiry,
+		refreshExpiry: cfg.RefreshExpiry,
+		algorithm:     cfg.JWTAlgorithm,
+		rotateEvery:   cfg.JWTKeyRotation,
+		issuer:        cfg.JWTIssuer,
+		audience:      cfg.JWTAudience,
+		redisService:  redisService,
+		keys:          make(map[string]*signingKey),
+	}
+
+	switch s.algorithm {
+	case JWTAlgorithmHS256:
+		if s.secret == "" {
+			return nil, fmt.Errorf("JWT_SECRET is required for HS256")
+		}
+		return s, nil
+	case JWTAlgorithmRS256, JWTAlgorithmEdDSA:
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %s", s.algorithm)
+	}
+
+	if len(cfg.JWTKeyEncryptionKey) == 0 {
+		return nil, fmt.Errorf("JWT_KEY_ENCRYPTION_KEY is required for %s", s.algorithm)
+	}
+	block, err := aes.NewCipher(cfg.JWTKeyEncryptionKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid JWT key encryption key: %v", err)
+	}
+	if s.keyCipher, err = cipher.NewGCM(block); err != nil {
+		return nil, fmt.Errorf("invalid JWT key encryption key: %v", err)
+	}
+
+	if err := s.reloadKeys(); err != nil {
+		return nil, err
+	}
+	if s.currentKey() == nil {
+		if err := s.rotate(); err != nil {
+			return nil, err
+		}
 	}
+
+	return s, nil
+}
+
+// RefreshExpiry is the lifetime of refresh tokens and of the login session
+// they keep alive.
+func (s *JWTService) RefreshExpiry() time.Duration {
+	return s.refreshExpiry
+}
+
+func (s *JWTService) asymmetric() bool {
+	return s.algorithm != JWTAlgorithmHS256
 }
 
 type Claims struct {
-	UserID    int64  `json:"user_id"`
-	SessionID string `json:"session_id"`
+	UserID    int64       `json:"user_id"`
+	SessionID string      `json:"session_id"`
+	Role      models.Role `json:"role,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func (s *JWTService) GenerateToken(userID int64, sessionID string) (*models.AuthResponse, error) {
+func (s *JWTService) GenerateToken(userID int64, sessionID string, role models.Role) (*models.AuthResponse, error) {
 	expirationTime := time.Now().Add(s.expiry)
 
 	claims := &Claims{
 		UserID:    userID,
 		SessionID: sessionID,
+		Role:      role,
 		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
 			ExpiresAt: jwt.NewNumericDate(expirationTime),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
+	if s.audience != "" {
+		claims.Audience = jwt.ClaimStrings{s.audience}
+	}
+
+	var tokenString string
+	var err error
+	if s.asymmetric() {
+		key := s.currentKey()
+		if key == nil {
+			return nil, fmt.Errorf("no signing key available")
+		}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(s.secret))
+		token := jwt.NewWithClaims(jwt.GetSigningMethod(key.algorithm), claims)
+		token.Header["kid"] = key.id
+		tokenString, err = token.SignedString(key.private)
+	} else {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		tokenString, err = token.SignedString([]byte(s.secret))
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -55,20 +171,317 @@ func (s *JWTService) GenerateToken(userID int64, sessionID string) (*models.Auth
 func (s *JWTService) ValidateToken(tokenString string) (*Claims, error) {
 	claims := &Claims{}
 
-	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+	var options []jwt.ParserOption
+	if s.issuer != "" {
+		options = append(options, jwt.WithIssuer(s.issuer))
+	}
+	if s.audience != "" {
+		options = append(options, jwt.WithAudience(s.audience))
+	}
+
+	token, err := jwt.ParseWithClaims(tokenString, claims, s.verificationKey, options...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	return claims, nil
+}
+
+// verificationKey only accepts the configured algorithm, so a token cannot
+// pick a weaker one.
+func (s *JWTService) verificationKey(token *jwt.Token) (interface{}, error) {
+	if !s.asymmetric() {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 		}
 		return []byte(s.secret), nil
-	})
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		return nil, fmt.Errorf("token has no kid")
+	}
+
+	key := s.lookupKey(kid)
+	if key == nil {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+
+	return key.private.Public(), nil
+}
+
+func (s *JWTService) currentKey() *signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.current
+}
+
+// lookupKey finds a verification key, reloading once if another instance
+// may have rotated in a key we have not seen yet.
+func (s *JWTService) lookupKey(kid string) *signingKey {
+	s.mu.RLock()
+	key := s.keys[kid]
+	stale := time.Since(s.lastReload) > jwtKeyMissReload
+	s.mu.RUnlock()
+
+	if key != nil || !stale {
+		return key
+	}
+
+	if err := s.reloadKeys(); err != nil {
+		log.Printf("JWT keys: %v", err)
+		return nil
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.keys[kid]
+}
+
+func (s *JWTService) reloadKeys() error {
+	stored, err := s.redisService.ListSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	keys := make(map[string]*signingKey, len(stored))
+	var current *signingKey
+	for _, sk := range stored {
+		key, err := s.parseSigningKey(sk)
+		if err != nil {
+			log.Printf("Skipping JWT key %s: %v", sk.ID, err)
+			continue
+		}
+		keys[key.id] = key
+
+		// Keys saved before encryption are sealed on first sight
+		if sk.EncryptedKey == "" {
+			if err := s.sealSigningKey(sk); err != nil {
+				log.Printf("JWT keys: %v", err)
+			} else if err := s.redisService.SaveSigningKey(sk); err != nil {
+				log.Printf("JWT keys: %v", err)
+			}
+		}
+
+		if key.algorithm == s.algorithm && (current == nil || key.createdAt.After(current.createdAt)) {
+			current = key
+		}
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.current = current
+	s.lastReload = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
 
+// rotate adds a fresh signing key and drops keys that can no longer have
+// signed an unexpired token.
+func (s *JWTService) rotate() error {
+	key, err := generateSigningKey(s.algorithm)
+	if err != nil {
+		return err
+	}
+	if err := s.sealSigningKey(key); err != nil {
+		return err
+	}
+	if err := s.redisService.SaveSigningKey(key); err != nil {
+		return err
+	}
+	log.Printf("JWT keys: rotated in %s key %s", key.Algorithm, key.ID)
+
+	// A key signs for one rotation period (plus reload lag on other
+	// instances), then its tokens live for one more access token lifetime
+	retireBefore := time.Now().Add(-(s.rotateEvery + s.expiry + 2*jwtKeyReloadInterval))
+
+	stored, err := s.redisService.ListSigningKeys()
+	if err != nil {
+		return err
+	}
+	for _, sk := range stored {
+		if sk.ID != key.ID && sk.CreatedAt.Before(retireBefore) {
+			s.redisService.DeleteSigningKey(sk.ID)
+		}
+	}
+
+	return s.reloadKeys()
+}
+
+// RunRotation keeps the key set fresh until ctx ends. Every instance
+// reloads; only the one holding the lock generates a new key.
+func (s *JWTService) RunRotation(ctx context.Context) {
+	if !s.asymmetric() {
+		return
+	}
+
+	ticker := time.NewTicker(jwtKeyReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := s.reloadKeys(); err != nil {
+			log.Printf("JWT keys: %v", err)
+			continue
+		}
+
+		current := s.currentKey()
+		if current != nil && time.Since(current.createdAt) < s.rotateEvery {
+			continue
+		}
+
+		locked, err := s.redisService.AcquireKeyRotationLock(jwtKeyReloadInterval)
+		if err != nil || !locked {
+			continue
+		}
+		if err := s.rotate(); err != nil {
+			log.Printf("JWT key rotation failed: %v", err)
+		}
+	}
+}
+
+// JWKS returns the public keys that may have signed a live token.
+func (s *JWTService) JWKS() []models.JWK {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	jwks := make([]models.JWK, 0, len(s.keys))
+	for _, key := range s.keys {
+		jwk := models.JWK{
+			KeyID:     key.id,
+			Use:       "sig",
+			Algorithm: key.algorithm,
+		}
+
+		switch pub := key.private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(pub)
+		default:
+			continue
+		}
+
+		jwks = append(jwks, jwk)
+	}
+
+	return jwks
+}
+
+func generateSigningKey(algorithm string) (*models.SigningKey, error) {
+	var private crypto.Signer
+	var err error
+
+	switch algorithm {
+	case JWTAlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case JWTAlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm: %s", algorithm)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %v", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode signing key: %v", err)
+	}
+
+	return &models.SigningKey{
+		ID:         uuid.New().String(),
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		CreatedAt:  time.Now(),
+	}, nil
+}
+
+// sealSigningKey encrypts the private key in place. The key ID is bound in
+// as additional data, so a sealed key cannot be swapped onto another ID.
+func (s *JWTService) sealSigningKey(sk *models.SigningKey) error {
+	nonce := make([]byte, s.keyCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to seal signing key: %v", err)
+	}
+
+	sealed := s.keyCipher.Seal(nonce, nonce, []byte(sk.PrivateKey), []byte(sk.ID))
+	sk.EncryptedKey = base64.StdEncoding.EncodeToString(sealed)
+	sk.PrivateKey = ""
+	return nil
+}
+
+func (s *JWTService) openSigningKey(sk *models.SigningKey) ([]byte, error) {
+	if sk.EncryptedKey == "" {
+		return []byte(sk.PrivateKey), nil
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(sk.EncryptedKey)
+	if err != nil || len(sealed) < s.keyCipher.NonceSize() {
+		return nil, fmt.Errorf("invalid sealed key")
+	}
+
+	nonceSize := s.keyCipher.NonceSize()
+	plain, err := s.keyCipher.Open(nil, sealed[:nonceSize], sealed[nonceSize:], []byte(sk.ID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to unseal key, wrong JWT_KEY_ENCRYPTION_KEY?")
+	}
+	return plain, nil
+}
+
+func (s *JWTService) parseSigningKey(sk *models.SigningKey) (*signingKey, error) {
+	plain, err := s.openSigningKey(sk)
 	if err != nil {
 		return nil, err
 	}
 
-	if !token.Valid {
-		return nil, fmt.Errorf("invalid token")
+	block, _ := pem.Decode(plain)
+	if block == nil {
+		return nil, fmt.Errorf("invalid PEM")
 	}
 
-	return claims, nil
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid private key: %v", err)
+	}
+
+	var private crypto.Signer
+	switch key := parsed.(type) {
+	case *rsa.PrivateKey:
+		if sk.Algorithm != JWTAlgorithmRS256 {
+			return nil, fmt.Errorf("RSA key labelled %s", sk.Algorithm)
+		}
+		private = key
+	case ed25519.PrivateKey:
+		if sk.Algorithm != JWTAlgorithmEdDSA {
+			return nil, fmt.Errorf("Ed25519 key labelled %s", sk.Algorithm)
+		}
+		private = key
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	return &signingKey{
+		id:        sk.ID,
+		algorithm: sk.Algorithm,
+		createdAt: sk.CreatedAt,
+		private:   private,
+	}, nil
 }
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"sample-miniapp-backend/internal/models"
+)
+
+func (s *RedisService) SaveSigningKey(key *models.SigningKey) error {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return fmt.Errorf("failed to marshal signing key: %v", err)
+	}
+	if err := s.client.HSet(s.ctx, KeyJWTSigningKeys, key.ID, data).Err(); err != nil {
+		return fmt.Errorf("failed to save signing key: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisService) ListSigningKeys() ([]*models.SigningKey, error) {
+	values, err := s.client.HGetAll(s.ctx, KeyJWTSigningKeys).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list signing keys: %v", err)
+	}
+
+	keys := make([]*models.SigningKey, 0, len(values))
+	for _, data := range values {
+		var key models.SigningKey
+		if err := json.Unmarshal([]byte(data), &key); err != nil {
+			continue
+		}
+		keys = append(keys, &key)
+	}
+	return keys, nil
+}
+
+func (s *RedisService) DeleteSigningKey(kid string) error {
+	return s.client.HDel(s.ctx, KeyJWTSigningKeys, kid).Err()
+}
+
+// AcquireKeyRotationLock makes sure only one instance rotates at a time.
+func (s *RedisService) AcquireKeyRotationLock(ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(s.ctx, KeyJWTRotationLock, 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire rotation lock: %v", err)
+	}
+	return ok, nil
+}
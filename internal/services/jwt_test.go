@@ -0,0 +1,151 @@
+package services_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestJWTServiceHS256(t *testing.T) {
+	cfg := &config.Config{
+		JWTSecret:    "test-secret",
+		JWTExpiry:    time.Minute,
+		JWTAlgorithm: services.JWTAlgorithmHS256,
+		JWTIssuer:    "test-issuer",
+		JWTAudience:  "test-audience",
+	}
+
+	jwtService, err := services.NewJWTService(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to create JWT service: %v", err)
+	}
+
+	auth, err := jwtService.GenerateToken(42, "session-1", models.RoleAdmin)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	claims, err := jwtService.ValidateToken(auth.Token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if claims.UserID != 42 || claims.SessionID != "session-1" || claims.Role != models.RoleAdmin {
+		t.Errorf("Unexpected claims: %+v", claims)
+	}
+	if claims.Issuer != "test-issuer" || len(claims.Audience) != 1 || claims.Audience[0] != "test-audience" {
+		t.Errorf("Unexpected iss/aud: %q %v", claims.Issuer, claims.Audience)
+	}
+	if len(jwtService.JWKS()) != 0 {
+		t.Error("HS256 should not publish keys")
+	}
+
+	otherAudience := *cfg
+	otherAudience.JWTAudience = "other-audience"
+	other, err := services.NewJWTService(&otherAudience, nil)
+	if err != nil {
+		t.Fatalf("Failed to create JWT service: %v", err)
+	}
+	if _, err := other.ValidateToken(auth.Token); err == nil {
+		t.Error("Token accepted by a service with another audience")
+	}
+
+	otherIssuer := *cfg
+	otherIssuer.JWTIssuer = "other-issuer"
+	other, err = services.NewJWTService(&otherIssuer, nil)
+	if err != nil {
+		t.Fatalf("Failed to create JWT service: %v", err)
+	}
+	if _, err := other.ValidateToken(auth.Token); err == nil {
+		t.Error("Token accepted by a service with another issuer")
+	}
+
+	cfg.JWTSecret = ""
+	if _, err := services.NewJWTService(cfg, nil); err == nil {
+		t.Error("Expected an error without a secret")
+	}
+}
+
+func TestJWTServiceRequiresKeyEncryptionKey(t *testing.T) {
+	cfg := &config.Config{
+		JWTExpiry:      time.Minute,
+		JWTAlgorithm:   services.JWTAlgorithmEdDSA,
+		JWTKeyRotation: time.Hour,
+	}
+
+	if _, err := services.NewJWTService(cfg, nil); err == nil {
+		t.Error("Expected an error without a key encryption key")
+	}
+}
+
+func TestJWTServiceEdDSA(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:            "localhost:6379",
+		RedisPass:           "",
+		RedisDB:             0,
+		JWTExpiry:           time.Minute,
+		JWTAlgorithm:        services.JWTAlgorithmEdDSA,
+		JWTKeyRotation:      time.Hour,
+		JWTKeyEncryptionKey: bytes.Repeat([]byte{7}, 32),
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	jwtService, err := services.NewJWTService(cfg, redisService)
+	if err != nil {
+		t.Fatalf("Failed to create JWT service: %v", err)
+	}
+
+	auth, err := jwtService.GenerateToken(42, "session-1", models.RolePlayer)
+	if err != nil {
+		t.Fatalf("Failed to generate token: %v", err)
+	}
+
+	claims, err := jwtService.ValidateToken(auth.Token)
+	if err != nil {
+		t.Fatalf("Failed to validate token: %v", err)
+	}
+	if claims.UserID != 42 {
+		t.Errorf("Expected user 42, got %d", claims.UserID)
+	}
+
+	stored, err := redisService.ListSigningKeys()
+	if err != nil {
+		t.Fatalf("Failed to list signing keys: %v", err)
+	}
+	for _, sk := range stored {
+		if sk.PrivateKey != "" || sk.EncryptedKey == "" || strings.Contains(sk.EncryptedKey, "PRIVATE KEY") {
+			t.Errorf("Signing key %s is stored unencrypted", sk.ID)
+		}
+	}
+
+	found := false
+	for _, jwk := range jwtService.JWKS() {
+		if jwk.KeyType == "OKP" && jwk.Curve == "Ed25519" && jwk.X != "" {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("Expected an Ed25519 key in the JWKS")
+	}
+
+	hs256, err := services.NewJWTService(&config.Config{
+		JWTSecret:    "test-secret",
+		JWTExpiry:    time.Minute,
+		JWTAlgorithm: services.JWTAlgorithmHS256,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create JWT service: %v", err)
+	}
+	if _, err := hs256.ValidateToken(auth.Token); err == nil {
+		t.Error("HS256 service accepted an EdDSA token")
+	}
+}
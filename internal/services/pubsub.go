@@ -0,0 +1,63 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	backplaneMinBackoff = time.Second
+	backplaneMaxBackoff = 30 * time.Second
+)
+
+// PublishBackplane sends a payload to every instance listening on the
+// WebSocket backplane, including this one.
+func (s *RedisService) PublishBackplane(payload []byte) error {
+	if err := s.client.Publish(s.ctx, KeyWSBackplane, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish to backplane: %v", err)
+	}
+	return nil
+}
+
+// SubscribeBackplane delivers backplane payloads to handle, in publish order,
+// until ctx is cancelled. A dropped subscription is re-established with
+// backoff; anything published while disconnected is lost.
+func (s *RedisService) SubscribeBackplane(ctx context.Context, handle func([]byte)) {
+	backoff := backplaneMinBackoff
+
+	for ctx.Err() == nil {
+		pubsub := s.client.Subscribe(ctx, KeyWSBackplane)
+
+		if _, err := pubsub.Receive(ctx); err != nil {
+			pubsub.Close()
+			if ctx.Err() != nil {
+				return
+			}
+			log.Printf("Backplane subscribe failed, retrying in %s: %v", backoff, err)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			if backoff *= 2; backoff > backplaneMaxBackoff {
+				backoff = backplaneMaxBackoff
+			}
+			continue
+		}
+
+		backoff = backplaneMinBackoff
+		for {
+			msg, err := pubsub.ReceiveMessage(ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("Backplane connection lost: %v", err)
+				}
+				break
+			}
+			handle([]byte(msg.Payload))
+		}
+		pubsub.Close()
+	}
+}
@@ -10,12 +10,20 @@ import (
 	"sample-miniapp-backend/internal/config"
 	"sample-miniapp-backend/internal/models"
 
+	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisService struct {
-	client *redis.Client
-	ctx    context.Context
+	client         *redis.Client
+	ctx            context.Context
+	archive        ArchiveStore
+	walletNotifier WalletNotifier
+}
+
+// SetWalletNotifier registers who gets pushed every wallet change.
+func (s *RedisService) SetWalletNotifier(n WalletNotifier) {
+	s.walletNotifier = n
 }
 
 func NewRedisService(cfg *config.Config) (*RedisService, error) {
@@ -48,7 +56,53 @@ func (s *RedisService) StoreUserSession(session *models.UserSession, expiry time
 		return err
 	}
 
-	return s.client.Set(s.ctx, key, data, expiry).Err()
+	// The index outlives any one session; stale members are pruned on list
+	indexKey := fmt.Sprintf(KeyUserSessions, session.ID)
+	pipe := s.client.TxPipeline()
+	pipe.Set(s.ctx, key, data, expiry)
+	pipe.SAdd(s.ctx, indexKey, session.SessionID)
+	pipe.Expire(s.ctx, indexKey, TTLUserInfo)
+	_, err = pipe.Exec(s.ctx)
+	return err
+}
+
+// SessionExists is the cheap check run on every authenticated request.
+func (s *RedisService) SessionExists(userID int64, sessionID string) (bool, error) {
+	n, err := s.client.Exists(s.ctx, fmt.Sprintf(KeyUserSession, userID, sessionID)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check session: %v", err)
+	}
+	return n > 0, nil
+}
+
+// ListUserSessions returns the user's live sessions, one per device login.
+func (s *RedisService) ListUserSessions(userID int64) ([]*models.UserSession, error) {
+	indexKey := fmt.Sprintf(KeyUserSessions, userID)
+
+	ids, err := s.client.SMembers(s.ctx, indexKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	sessions := make([]*models.UserSession, 0, len(ids))
+	for _, id := range ids {
+		data, err := s.client.Get(s.ctx, fmt.Sprintf(KeyUserSession, userID, id)).Result()
+		if err == redis.Nil {
+			s.client.SRem(s.ctx, indexKey, id)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get session: %v", err)
+		}
+
+		var session models.UserSession
+		if err := json.Unmarshal([]byte(data), &session); err != nil {
+			continue
+		}
+		sessions = append(sessions, &session)
+	}
+
+	return sessions, nil
 }
 
 func (s *RedisService) GetUserSession(userID int64, sessionID string) (*models.UserSession, error) {
@@ -65,16 +119,64 @@ func (s *RedisService) GetUserSession(userID int64, sessionID string) (*models.U
 		return nil, err
 	}
 
+	// Keep the TTL: only a token refresh slides the session forward
 	session.LastAccessed = time.Now()
 	updatedData, _ := json.Marshal(session)
-	s.client.Set(s.ctx, key, updatedData, 24*time.Hour)
+	s.client.SetArgs(s.ctx, key, updatedData, redis.SetArgs{KeepTTL: true})
 
 	return &session, nil
 }
 
+// TouchUserSession slides a session's expiry forward. It reports false if
+// the session has already ended.
+func (s *RedisService) TouchUserSession(userID int64, sessionID string, ttl time.Duration) (bool, error) {
+	ok, err := s.client.Expire(s.ctx, fmt.Sprintf(KeyUserSession, userID, sessionID), ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to extend session: %v", err)
+	}
+	return ok, nil
+}
+
 func (s *RedisService) DeleteUserSession(userID int64, sessionID string) error {
 	key := fmt.Sprintf("user:%d:session:%s", userID, sessionID)
-	return s.client.Del(s.ctx, key).Err()
+
+	pipe := s.client.TxPipeline()
+	pipe.Del(s.ctx, key)
+	pipe.SRem(s.ctx, fmt.Sprintf(KeyUserSessions, userID), sessionID)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+// DeleteAllUserSessions logs a user out everywhere.
+func (s *RedisService) DeleteAllUserSessions(userID int64) (int, error) {
+	indexKey := fmt.Sprintf(KeyUserSessions, userID)
+
+	ids, err := s.client.SMembers(s.ctx, indexKey).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	for _, id := range ids {
+		pipe.Del(s.ctx, fmt.Sprintf(KeyUserSession, userID, id))
+	}
+	pipe.Del(s.ctx, indexKey)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return 0, fmt.Errorf("failed to delete sessions: %v", err)
+	}
+
+	return len(ids), nil
+}
+
+// MarkInitDataUsed records an initData hash until the initData would have
+// expired anyway. It returns false if the hash was already used. Login
+// Widget payloads share it, since their hashes cannot collide with initData.
+func (s *RedisService) MarkInitDataUsed(hash string, ttl time.Duration) (bool, error) {
+	ok, err := s.client.SetNX(s.ctx, fmt.Sprintf(KeyInitDataUsed, hash), 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to record initData: %v", err)
+	}
+	return ok, nil
 }
 
 func (s *RedisService) StoreUser(user *models.TelegramUser) error {
@@ -149,54 +251,151 @@ func (s *RedisService) SaveWallet(wallet *models.Wallet) error {
 	return s.client.Set(s.ctx, key, data, 0).Err()
 }
 
+// UpdateWalletBalance is the deposit and withdrawal path, so it refuses
+// accounts that may not move money. Staff corrections use
+// AdjustWalletBalance instead. A positive amount is recorded as a deposit
+// and a negative one as a withdrawal, which is what fires their events.
 func (s *RedisService) UpdateWalletBalance(userID int64, amount float64) error {
-	tx := s.client.TxPipeline()
+	if err := s.CheckCanMoveMoney(userID); err != nil {
+		return err
+	}
 
-	key := fmt.Sprintf("wallet:%d", userID)
+	// Make sure the wallet exists; GetWallet creates it with the starting balance
+	if _, err := s.GetWallet(userID); err != nil {
+		return err
+	}
 
-	getCmd := tx.Get(s.ctx, key)
+	var before float64
+	wallet, err := s.mutateWallet(userID, func(wallet *models.Wallet) (float64, string, error) {
+		if wallet.Balance+amount < 0 {
+			return 0, "", fmt.Errorf("insufficient balance")
+		}
+		before = wallet.Balance
+		wallet.Balance += amount
+		return amount, "balance_adjusted", nil
+	})
+	if err != nil {
+		return err
+	}
 
-	_, err := tx.Exec(s.ctx)
-	if err != nil && err != redis.Nil {
-		return fmt.Errorf("failed to get wallet in transaction: %v", err)
+	tx := &models.Transaction{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Type:          models.TransactionTypeDeposit,
+		Amount:        amount,
+		BalanceBefore: before,
+		BalanceAfter:  wallet.Balance,
+		Description:   fmt.Sprintf("Deposited %.2f", amount),
+		CreatedAt:     time.Now(),
 	}
+	if amount < 0 {
+		tx.Type = models.TransactionTypeWithdraw
+		tx.Description = fmt.Sprintf("Withdrew %.2f", -amount)
+	}
+	return s.SaveTransaction(tx)
+}
 
-	var wallet models.Wallet
+func (s *RedisService) LockBalanceForGame(userID int64, amount float64) error {
+	_, err := s.mutateWallet(userID, func(wallet *models.Wallet) (float64, string, error) {
+		if wallet.Balance < amount {
+			return 0, "", fmt.Errorf("insufficient balance")
+		}
 
-	if err == redis.Nil {
-		wallet = models.Wallet{
-			UserID:  userID,
-			Balance: 10000,
+		wallet.Balance -= amount
+		wallet.LockedBalance += amount
+		wallet.TotalWagered += amount
+		return -amount, "bet_locked", nil
+	})
+	return err
+}
+
+func (s *RedisService) ReleaseBalanceFromGame(userID int64, amount float64, won bool, winnings float64) error {
+	_, err := s.mutateWallet(userID, func(wallet *models.Wallet) (float64, string, error) {
+		// If locked balance is less than requested amount, release what we have to avoid negative locked balance
+		releaseAmt := amount
+		if wallet.LockedBalance < releaseAmt {
+			releaseAmt = wallet.LockedBalance
 		}
-	} else {
-		data, err := getCmd.Result()
-		if err != nil {
-			return fmt.Errorf("failed to get wallet data: %v", err)
+
+		wallet.LockedBalance -= releaseAmt
+		if wallet.LockedBalance < 0 {
+			wallet.LockedBalance = 0
 		}
 
-		if err := json.Unmarshal([]byte(data), &wallet); err != nil {
-			return fmt.Errorf("failed to unmarshal wallet: %v", err)
+		if won {
+			wallet.Balance += winnings
+			wallet.TotalWon += winnings
+			return winnings, "game_won", nil
 		}
-	}
+		return 0, "game_lost", nil
+	})
+	return err
+}
 
-	wallet.Balance += amount
-	if wallet.Balance < 0 {
-		return fmt.Errorf("insufficient balance")
-	}
+// RefundBalanceFromGame returns a locked stake to the balance and records a
+// refund transaction. Unlike a win it is taken off TotalWagered and never
+// counted in TotalWon.
+func (s *RedisService) RefundBalanceFromGame(userID int64, amount float64, gameID string) (*models.Wallet, error) {
+	var before, refunded float64
+	wallet, err := s.mutateWallet(userID, func(wallet *models.Wallet) (float64, string, error) {
+		refunded = amount
+		if wallet.LockedBalance < refunded {
+			refunded = wallet.LockedBalance
+		}
 
-	updatedData, err := json.Marshal(wallet)
+		before = wallet.Balance
+		wallet.LockedBalance -= refunded
+		wallet.Balance += refunded
+		wallet.TotalWagered -= refunded
+		if wallet.TotalWagered < 0 {
+			wallet.TotalWagered = 0
+		}
+		return refunded, "game_refunded", nil
+	})
 	if err != nil {
-		return fmt.Errorf("failed to marshal updated wallet: %v", err)
+		return nil, err
 	}
 
-	return s.client.Set(s.ctx, key, updatedData, 0).Err()
+	tx := &models.Transaction{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		Type:          models.TransactionTypeRefund,
+		Amount:        refunded,
+		BalanceBefore: before,
+		BalanceAfter:  wallet.Balance,
+		GameID:        gameID,
+		Description:   fmt.Sprintf("Refunded %.2f stake", refunded),
+		CreatedAt:     time.Now(),
+	}
+	if err := s.SaveTransaction(tx); err != nil {
+		return wallet, err
+	}
+
+	return wallet, nil
 }
 
-func (s *RedisService) LockBalanceForGame(userID int64, amount float64) error {
+// IncrementWalletNonce advances the provably fair nonce without touching the
+// balance, so it never overwrites a concurrent balance change.
+func (s *RedisService) IncrementWalletNonce(userID int64) error {
+	_, err := s.mutateWallet(userID, func(wallet *models.Wallet) (float64, string, error) {
+		wallet.Nonce++
+		return 0, "", nil
+	})
+	return err
+}
+
+// mutateWallet applies a change to a wallet under WATCH, bumping its version
+// so clients can order the balance pushes. apply returns the balance delta
+// and the reason to publish; an empty reason publishes nothing.
+func (s *RedisService) mutateWallet(userID int64, apply func(wallet *models.Wallet) (float64, string, error)) (*models.Wallet, error) {
 	key := fmt.Sprintf("wallet:%d", userID)
 
 	// Retry a few times in case of transaction conflicts
 	for i := 0; i < 3; i++ {
+		var wallet models.Wallet
+		var delta float64
+		var reason string
+
 		err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
 			data, err := tx.Get(s.ctx, key).Result()
 			if err == redis.Nil {
@@ -206,18 +405,14 @@ func (s *RedisService) LockBalanceForGame(userID int64, amount float64) error {
 				return err
 			}
 
-			var wallet models.Wallet
 			if err := json.Unmarshal([]byte(data), &wallet); err != nil {
 				return fmt.Errorf("failed to unmarshal wallet: %v", err)
 			}
 
-			if wallet.Balance < amount {
-				return fmt.Errorf("insufficient balance")
+			if delta, reason, err = apply(&wallet); err != nil {
+				return err
 			}
-
-			wallet.Balance -= amount
-			wallet.LockedBalance += amount
-			wallet.TotalWagered += amount
+			wallet.Version++
 
 			updated, err := json.Marshal(wallet)
 			if err != nil {
@@ -232,74 +427,34 @@ func (s *RedisService) LockBalanceForGame(userID int64, amount float64) error {
 		}, key)
 
 		if err == nil {
-			return nil
+			if reason != "" {
+				s.publishWalletChange(&wallet, delta, reason)
+			}
+			return &wallet, nil
 		}
 		// retry on optimistic lock failure
 		if err == redis.TxFailedErr {
 			continue
 		}
-		return err
+		return nil, err
 	}
 
-	return fmt.Errorf("failed to lock balance: transaction conflict")
+	return nil, fmt.Errorf("failed to update wallet: transaction conflict")
 }
 
-func (s *RedisService) ReleaseBalanceFromGame(userID int64, amount float64, won bool, winnings float64) error {
-	key := fmt.Sprintf("wallet:%d", userID)
-
-	for i := 0; i < 3; i++ {
-		err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
-			data, err := tx.Get(s.ctx, key).Result()
-			if err == redis.Nil {
-				return fmt.Errorf("wallet not found")
-			}
-			if err != nil {
-				return err
-			}
-
-			var wallet models.Wallet
-			if err := json.Unmarshal([]byte(data), &wallet); err != nil {
-				return fmt.Errorf("failed to unmarshal wallet: %v", err)
-			}
-
-			// If locked balance is less than requested amount, release what we have to avoid negative locked balance
-			releaseAmt := amount
-			if wallet.LockedBalance < releaseAmt {
-				releaseAmt = wallet.LockedBalance
-			}
-
-			wallet.LockedBalance -= releaseAmt
-			if wallet.LockedBalance < 0 {
-				wallet.LockedBalance = 0
-			}
-
-			if won {
-				wallet.Balance += winnings
-				wallet.TotalWon += winnings
-			}
-
-			updated, err := json.Marshal(wallet)
-			if err != nil {
-				return fmt.Errorf("failed to marshal updated wallet: %v", err)
-			}
-
-			_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
-				pipe.Set(s.ctx, key, updated, 0)
-				return nil
-			})
-			return err
-		}, key)
+func (s *RedisService) publishWalletChange(wallet *models.Wallet, delta float64, reason string) {
+	s.publishEvent(&models.Event{
+		Type:          models.EventWalletChanged,
+		UserID:        wallet.UserID,
+		Amount:        delta,
+		Balance:       wallet.Balance,
+		WalletVersion: wallet.Version,
+		Reason:        reason,
+	})
 
-		if err == nil {
-			return nil
-		}
-		if err == redis.TxFailedErr {
-			continue
-		}
-		return err
+	if s.walletNotifier != nil {
+		s.walletNotifier.BroadcastBalance(wallet, reason)
 	}
-
-	return fmt.Errorf("failed to release balance: transaction conflict")
 }
 
 func (s *RedisService) SaveGameSession(session *models.GameSession) error {
@@ -330,7 +485,7 @@ func (s *RedisService) GetGameSession(gameID string) (*models.GameSession, error
 	data, err := s.client.Get(s.ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("game not found: %s", gameID)
+			return s.getArchivedGameSession(gameID)
 		}
 		return nil, fmt.Errorf("failed to get game session: %v", err)
 	}
@@ -343,21 +498,124 @@ func (s *RedisService) GetGameSession(gameID string) (*models.GameSession, error
 	return &session, nil
 }
 
+// UpdateGameSession saves in-play changes such as the multiplier or the
+// revealed tiles. It never changes the status, and it refuses to write once
+// the stored game is settled, so a late tick or reveal cannot overwrite a
+// settlement made by TransitionGameSession in the meantime. It also fails
+// with ErrGameChanged when session was read before the last stored write, so
+// two reveals cannot each save their own tile over the other's.
 func (s *RedisService) UpdateGameSession(session *models.GameSession) error {
-	existing, err := s.GetGameSession(session.ID)
-	if err != nil || existing == nil {
+	key := fmt.Sprintf(KeyGameSession, session.ID)
+
+	for i := 0; i < 3; i++ {
+		err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(s.ctx, key).Result()
+			if err == redis.Nil {
+				return fmt.Errorf("game not found: %s", session.ID)
+			}
+			if err != nil {
+				return err
+			}
+
+			var stored models.GameSession
+			if err := json.Unmarshal([]byte(data), &stored); err != nil {
+				return fmt.Errorf("failed to unmarshal game session: %v", err)
+			}
+
+			if stored.Status.IsTerminal() {
+				return fmt.Errorf("%w: game already %s", models.ErrInvalidTransition, stored.Status)
+			}
+			// Status only ever moves through TransitionGameSession.
+			if stored.Status != session.Status {
+				return fmt.Errorf("%w: %s -> %s outside TransitionGameSession",
+					models.ErrInvalidTransition, stored.Status, session.Status)
+			}
+			if stored.Revision != session.Revision {
+				return fmt.Errorf("%w: revision %d, stored %d", ErrGameChanged, session.Revision, stored.Revision)
+			}
+
+			next := *session
+			next.Revision++
+			next.UpdatedAt = time.Now()
+
+			updated, err := json.Marshal(&next)
+			if err != nil {
+				return fmt.Errorf("failed to marshal updated game session: %v", err)
+			}
+
+			_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(s.ctx, key, updated, TTLGameSession)
+				return nil
+			})
+			if err == nil {
+				session.Revision = next.Revision
+				session.UpdatedAt = next.UpdatedAt
+			}
+			return err
+		}, key)
+
+		if err == nil {
+			return nil
+		}
+		if err == redis.TxFailedErr {
+			continue
+		}
 		return err
 	}
 
-	session.UpdatedAt = time.Now()
+	return fmt.Errorf("failed to update game session: transaction conflict")
+}
 
-	data, err := json.Marshal(session)
-	if err != nil {
-		return fmt.Errorf("failed to marshal updated game session: %v", err)
+// TransitionGameSession moves a game to a new status and persists it. The
+// move is validated against the status stored in Redis, not the caller's copy,
+// so two settlements racing each other (cashout vs crash) cannot both succeed.
+func (s *RedisService) TransitionGameSession(session *models.GameSession, to models.GameStatus) error {
+	key := fmt.Sprintf(KeyGameSession, session.ID)
+
+	for i := 0; i < 3; i++ {
+		err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(s.ctx, key).Result()
+			if err == redis.Nil {
+				return fmt.Errorf("game not found: %s", session.ID)
+			}
+			if err != nil {
+				return err
+			}
+
+			var stored models.GameSession
+			if err := json.Unmarshal([]byte(data), &stored); err != nil {
+				return fmt.Errorf("failed to unmarshal game session: %v", err)
+			}
+
+			session.Status = stored.Status
+			session.Transitions = stored.Transitions
+			if err := session.TransitionTo(to, time.Now()); err != nil {
+				return err
+			}
+			session.Revision = stored.Revision + 1
+
+			updated, err := json.Marshal(session)
+			if err != nil {
+				return fmt.Errorf("failed to marshal game session: %v", err)
+			}
+
+			_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(s.ctx, key, updated, TTLGameSession)
+				return nil
+			})
+			return err
+		}, key)
+
+		if err == nil {
+			return nil
+		}
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return err
 	}
 
-	key := fmt.Sprintf("game:session:%s", session.ID)
-	return s.client.Set(s.ctx, key, data, 7*24*time.Hour).Err()
+	return fmt.Errorf("failed to transition game session: transaction conflict")
 }
 
 func (s *RedisService) GetUserActiveGames(userID int64) ([]string, error) {
@@ -416,9 +674,26 @@ func (s *RedisService) SaveTransaction(tx *models.Transaction) error {
 	// Keep only last 100 transactions
 	s.client.ZRemRangeByRank(s.ctx, userTxKey, 0, -101)
 
+	switch tx.Type {
+	case models.TransactionTypeDeposit:
+		s.publishTransaction(models.EventDeposit, tx)
+	case models.TransactionTypeWithdraw:
+		s.publishTransaction(models.EventWithdrawal, tx)
+	}
+
 	return nil
 }
 
+func (s *RedisService) publishTransaction(eventType models.EventType, tx *models.Transaction) {
+	s.publishEvent(&models.Event{
+		Type:    eventType,
+		UserID:  tx.UserID,
+		Amount:  tx.Amount,
+		Balance: tx.BalanceAfter,
+		Reason:  tx.Description,
+	})
+}
+
 func (s *RedisService) GetUserTransactions(userID int64, limit int64) ([]*models.Transaction, error) {
 	if limit <= 0 || limit > 100 {
 		limit = 50
@@ -563,6 +838,9 @@ func (s *RedisService) BulkGetGameSessions(gameIDs []string) ([]*models.GameSess
 	for i, cmd := range cmds {
 		data, err := cmd.Result()
 		if err == redis.Nil {
+			if archived, err := s.getArchivedGameSession(gameIDs[i]); err == nil {
+				sessions = append(sessions, archived)
+			}
 			continue
 		}
 		if err != nil {
@@ -4,6 +4,7 @@ import "time"
 
 const (
 	KeyUserSession        = "user:%d:session:%s"
+	KeyUserSessions       = "user:%d:sessions"
 	KeyUserInfo           = "user:%d:info"
 	KeyWallet             = "wallet:%d"
 	KeyGameSession        = "game:session:%s"
@@ -13,12 +14,56 @@ const (
 	KeyUserTransactions   = "user:%d:transactions"
 	KeyRateLimit          = "ratelimit:%d:%s"
 	KeyBetPatterns        = "patterns:%d:bets"
+	KeyEventStream        = "events:stream"
+	KeyWebhook            = "webhook:%s"
+	KeyWebhooks           = "webhooks"
+	KeyWebhookDelivery    = "webhook:delivery:%s"
+	KeyWebhookDeliveries  = "webhooks:deliveries"
+	KeyWebhookRetryQueue  = "webhooks:retry"
+	KeyWebhookDeadLetter  = "webhooks:dlq"
+	KeyArchiveIndex       = "archive:games:%s" // shard -> game ID -> bundle@offset
+	KeyArchiveIndexLegacy = "archive:games"    // unsharded index from before sharding, read only
+	KeyArchiveLock        = "archive:running"
+	KeyWSBackplane        = "ws:backplane"
+	KeyIdempotency        = "idempotency:%d:%s"
+	KeyWSTopicSeq         = "ws:seq:%s"
+	KeyWSTopicBuffer      = "ws:buffer:%s"
+	KeyFeedAll            = "feed:all"
+	KeyFeedHighRollers    = "feed:high_rollers"
+	KeyFeedOptOut         = "feed:opted_out"
+	KeyRefreshToken       = "refresh:%s"         // by token hash
+	KeyRefreshRevoked     = "refresh:revoked:%s" // by session ID
+	KeyJWTSigningKeys     = "jwt:keys"
+	KeyJWTRotationLock    = "jwt:keys:rotating"
+	KeyInitDataUsed       = "telegram:initdata:%s" // by initData hash
+	KeyStreamTicket       = "stream:ticket:%s"     // by ticket hash
+	KeyUserRoles          = "roles"
+	KeyAccountState       = "user:%d:account"
+	KeyAdminAudit         = "admin:audit"
+	KeyAdminAuditUser     = "admin:audit:user:%d"
+	KeyPendingAdjustments = "admin:adjustments:pending" // ID -> adjustment
+	KeyGameConfig         = "game:config:%s"
+	KeyGameConfigHistory  = "game:config:%s:history"
+	KeyAPIKeys            = "apikeys"        // ID -> key record
+	KeyAPIKeyHashes       = "apikeys:hashes" // secret hash -> ID, active keys only
+	KeyAPIKeyLastUsed     = "apikeys:last_used"
+	KeyAPIKeyRateLimit    = "ratelimit:apikey:%s"
 
-	TTLUserSession = 24 * time.Hour
-	TTLUserInfo    = 30 * 24 * time.Hour // 30 days
-	TTLGameSession = 7 * 24 * time.Hour  // 7 days
-	TTLTransaction = 30 * 24 * time.Hour // 30 days
+	TTLUserSession     = 24 * time.Hour
+	TTLUserInfo        = 30 * 24 * time.Hour // 30 days
+	TTLGameSession     = 7 * 24 * time.Hour  // 7 days
+	TTLTransaction     = 30 * 24 * time.Hour // 30 days
+	TTLWebhookDelivery = 7 * 24 * time.Hour  // 7 days
+	TTLIdempotency     = 10 * time.Minute
+	TTLWSReplay        = 10 * time.Minute
+	TTLStreamTicket    = time.Minute
+	TTLPendingAdjust   = 24 * time.Hour // unapproved credits lapse after this
 
 	DefaultRateLimitBets    = 30 // Max 30 bets per minute
 	DefaultRateLimitCashout = 60 // Max 60 cashouts per minute
+
+	EventStreamMaxLen    = 100000 // Approximate cap, trimmed on write
+	GameConfigHistoryLen = 200
+	WSReplayBufferSize   = 100 // Messages kept per WebSocket topic
+	FeedMaxLen           = 50  // Entries kept per public feed
 )
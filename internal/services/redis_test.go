@@ -88,3 +88,99 @@ func TestRedisService(t *testing.T) {
 	redisService.DeleteGameSession(session.ID)
 	redisService.ClearBetRateLimit(userID)
 }
+
+func TestRefundBalanceFromGame(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	userID := time.Now().UnixNano() % 1000000000
+	defer redisService.DeleteWallet(userID)
+
+	start, err := redisService.GetWallet(userID)
+	if err != nil {
+		t.Fatalf("Failed to get wallet: %v", err)
+	}
+	if err := redisService.LockBalanceForGame(userID, 100); err != nil {
+		t.Fatalf("Failed to lock balance: %v", err)
+	}
+
+	wallet, err := redisService.RefundBalanceFromGame(userID, 100, "test_refund_game")
+	if err != nil {
+		t.Fatalf("Failed to refund: %v", err)
+	}
+	if wallet.Balance != start.Balance || wallet.LockedBalance != 0 {
+		t.Errorf("Expected balance %.0f with nothing locked, got %.0f with %.0f locked",
+			start.Balance, wallet.Balance, wallet.LockedBalance)
+	}
+	if wallet.TotalWon != start.TotalWon || wallet.TotalWagered != start.TotalWagered {
+		t.Errorf("Expected a refund to count as neither wagered nor won, got wagered %.0f won %.0f",
+			wallet.TotalWagered, wallet.TotalWon)
+	}
+
+	txs, err := redisService.GetUserTransactions(userID, 10)
+	if err != nil || len(txs) != 1 || txs[0].Type != models.TransactionTypeRefund || txs[0].GameID != "test_refund_game" {
+		t.Errorf("Expected one refund transaction, got %v (%v)", txs, err)
+	}
+}
+
+func TestDepositAndWithdrawalTransactions(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	userID := time.Now().UnixNano() % 1000000000
+	defer redisService.DeleteWallet(userID)
+
+	// Marks where this test's events start in the stream
+	marker, err := redisService.PublishEvent(&models.Event{Type: models.EventBetPlaced, UserID: userID})
+	if err != nil {
+		t.Fatalf("Failed to publish marker event: %v", err)
+	}
+
+	if err := redisService.UpdateWalletBalance(userID, 500); err != nil {
+		t.Fatalf("Failed to deposit: %v", err)
+	}
+	if err := redisService.UpdateWalletBalance(userID, -200); err != nil {
+		t.Fatalf("Failed to withdraw: %v", err)
+	}
+
+	txs, err := redisService.GetUserTransactions(userID, 10)
+	if err != nil || len(txs) != 2 {
+		t.Fatalf("Expected two transactions, got %v (%v)", txs, err)
+	}
+	types := map[models.TransactionType]bool{txs[0].Type: true, txs[1].Type: true}
+	if !types[models.TransactionTypeDeposit] || !types[models.TransactionTypeWithdraw] {
+		t.Errorf("Expected a deposit and a withdrawal, got %s and %s", txs[0].Type, txs[1].Type)
+	}
+
+	events, err := redisService.ReadEvents(marker, 1000)
+	if err != nil {
+		t.Fatalf("Failed to read events: %v", err)
+	}
+	seen := make(map[models.EventType]bool)
+	for _, event := range events {
+		if event.UserID == userID {
+			seen[event.Type] = true
+		}
+	}
+	if !seen[models.EventDeposit] || !seen[models.EventWithdrawal] {
+		t.Errorf("Expected deposit and withdrawal events, got %v", seen)
+	}
+}
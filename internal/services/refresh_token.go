@@ -0,0 +1,140 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"sample-miniapp-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used")
+)
+
+// Refresh tokens are opaque; only their SHA-256 is stored. Each login
+// session is one token family: every refresh rotates the token, and
+// presenting an already rotated token revokes the whole family.
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// IssueRefreshToken creates a refresh token for a login session.
+func (s *RedisService) IssueRefreshToken(userID int64, sessionID string, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate refresh token: %v", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	record := &models.RefreshToken{
+		UserID:    userID,
+		SessionID: sessionID,
+		CreatedAt: time.Now(),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal refresh token: %v", err)
+	}
+
+	key := fmt.Sprintf(KeyRefreshToken, hashRefreshToken(token))
+	if err := s.client.Set(s.ctx, key, data, ttl).Err(); err != nil {
+		return "", fmt.Errorf("failed to store refresh token: %v", err)
+	}
+
+	return token, nil
+}
+
+// RotateRefreshToken spends a refresh token and returns its record so the
+// caller can issue new tokens for the same session. A replayed token
+// revokes the family and ends the session.
+func (s *RedisService) RotateRefreshToken(token string) (*models.RefreshToken, error) {
+	key := fmt.Sprintf(KeyRefreshToken, hashRefreshToken(token))
+	var record models.RefreshToken
+
+	for i := 0; i < 3; i++ {
+		err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+			data, err := tx.Get(s.ctx, key).Result()
+			if err == redis.Nil {
+				return ErrRefreshTokenInvalid
+			}
+			if err != nil {
+				return err
+			}
+
+			if err := json.Unmarshal([]byte(data), &record); err != nil {
+				return fmt.Errorf("failed to unmarshal refresh token: %v", err)
+			}
+
+			revoked, err := tx.Exists(s.ctx, fmt.Sprintf(KeyRefreshRevoked, record.SessionID)).Result()
+			if err != nil {
+				return err
+			}
+			if revoked > 0 {
+				return ErrRefreshTokenInvalid
+			}
+
+			if record.Used {
+				return ErrRefreshTokenReused
+			}
+
+			record.Used = true
+			updated, err := json.Marshal(record)
+			if err != nil {
+				return fmt.Errorf("failed to marshal refresh token: %v", err)
+			}
+
+			// Keep the spent token until it expires so a replay is recognised
+			_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+				pipe.SetArgs(s.ctx, key, updated, redis.SetArgs{KeepTTL: true})
+				return nil
+			})
+			return err
+		}, key)
+
+		if err == nil {
+			return &record, nil
+		}
+		if err == ErrRefreshTokenReused {
+			log.Printf("Refresh token reuse for user %d, revoking session %s", record.UserID, record.SessionID)
+			s.RevokeRefreshFamily(record.UserID, record.SessionID, time.Until(record.ExpiresAt))
+			return nil, err
+		}
+		if err == redis.TxFailedErr {
+			continue
+		}
+		return nil, err
+	}
+
+	return nil, fmt.Errorf("failed to rotate refresh token: transaction conflict")
+}
+
+// RevokeRefreshFamily invalidates every refresh token of a login session and
+// ends the session itself.
+func (s *RedisService) RevokeRefreshFamily(userID int64, sessionID string, ttl time.Duration) error {
+	if ttl <= 0 {
+		ttl = time.Minute
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(s.ctx, fmt.Sprintf(KeyRefreshRevoked, sessionID), 1, ttl)
+	pipe.Del(s.ctx, fmt.Sprintf(KeyUserSession, userID, sessionID))
+	pipe.SRem(s.ctx, fmt.Sprintf(KeyUserSessions, userID), sessionID)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return fmt.Errorf("failed to revoke session: %v", err)
+	}
+	return nil
+}
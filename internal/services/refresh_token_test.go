@@ -0,0 +1,67 @@
+package services_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestRefreshTokenReuseRevokesFamily(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	userID := int64(999998)
+	sessionID := fmt.Sprintf("test-session-%d", time.Now().UnixNano())
+	session := &models.UserSession{
+		TelegramUser: models.TelegramUser{ID: userID},
+		SessionID:    sessionID,
+		CreatedAt:    time.Now(),
+	}
+	if err := redisService.StoreUserSession(session, time.Minute); err != nil {
+		t.Fatalf("Failed to store session: %v", err)
+	}
+	defer redisService.DeleteUserSession(userID, sessionID)
+
+	first, err := redisService.IssueRefreshToken(userID, sessionID, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to issue refresh token: %v", err)
+	}
+
+	record, err := redisService.RotateRefreshToken(first)
+	if err != nil {
+		t.Fatalf("First use should succeed: %v", err)
+	}
+	if record.UserID != userID || record.SessionID != sessionID {
+		t.Errorf("Unexpected token record: %+v", record)
+	}
+
+	second, err := redisService.IssueRefreshToken(userID, sessionID, time.Minute)
+	if err != nil {
+		t.Fatalf("Failed to issue rotated token: %v", err)
+	}
+
+	// Replaying the spent token must fail and take the family down with it
+	if _, err := redisService.RotateRefreshToken(first); !errors.Is(err, services.ErrRefreshTokenReused) {
+		t.Fatalf("Expected reuse to be detected, got %v", err)
+	}
+	if _, err := redisService.RotateRefreshToken(second); !errors.Is(err, services.ErrRefreshTokenInvalid) {
+		t.Errorf("Expected the rest of the family to be revoked, got %v", err)
+	}
+	if _, err := redisService.GetUserSession(userID, sessionID); err == nil {
+		t.Error("Expected the session to be ended")
+	}
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+
+	"sample-miniapp-backend/internal/models"
+)
+
+// RoleService resolves a user's role. Roles from configuration win so the
+// first admins can be bootstrapped; everyone else is looked up in Redis and
+// defaults to player.
+type RoleService struct {
+	redisService *RedisService
+	static       map[int64]models.Role
+}
+
+func NewRoleService(redisService *RedisService, static map[int64]models.Role) *RoleService {
+	if static == nil {
+		static = make(map[int64]models.Role)
+	}
+	return &RoleService{
+		redisService: redisService,
+		static:       static,
+	}
+}
+
+func (s *RoleService) GetRole(userID int64) (models.Role, error) {
+	if role, ok := s.static[userID]; ok {
+		return role, nil
+	}
+	return s.redisService.GetUserRole(userID)
+}
+
+// SetRole stores a role. Roles from configuration cannot be overridden.
+func (s *RoleService) SetRole(userID int64, role models.Role) error {
+	if !role.IsValid() {
+		return fmt.Errorf("invalid role: %s", role)
+	}
+	if _, ok := s.static[userID]; ok {
+		return fmt.Errorf("role for user %d is set in configuration", userID)
+	}
+	return s.redisService.SetUserRole(userID, role)
+}
+
+// ListRoles returns every user with a role other than player.
+func (s *RoleService) ListRoles() ([]models.RoleAssignment, error) {
+	stored, err := s.redisService.ListUserRoles()
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]models.RoleAssignment, 0, len(stored)+len(s.static))
+	for userID, role := range s.static {
+		assignments = append(assignments, models.RoleAssignment{UserID: userID, Role: role, Static: true})
+	}
+	for userID, role := range stored {
+		if _, ok := s.static[userID]; !ok {
+			assignments = append(assignments, models.RoleAssignment{UserID: userID, Role: role})
+		}
+	}
+
+	return assignments, nil
+}
+
+func (s *RedisService) GetUserRole(userID int64) (models.Role, error) {
+	value, err := s.client.HGet(s.ctx, KeyUserRoles, strconv.FormatInt(userID, 10)).Result()
+	if err == redis.Nil {
+		return models.RolePlayer, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get role: %v", err)
+	}
+
+	role := models.Role(value)
+	if !role.IsValid() {
+		return models.RolePlayer, nil
+	}
+	return role, nil
+}
+
+// SetUserRole stores a role. Setting player removes the entry.
+func (s *RedisService) SetUserRole(userID int64, role models.Role) error {
+	field := strconv.FormatInt(userID, 10)
+
+	var err error
+	if role == models.RolePlayer {
+		err = s.client.HDel(s.ctx, KeyUserRoles, field).Err()
+	} else {
+		err = s.client.HSet(s.ctx, KeyUserRoles, field, string(role)).Err()
+	}
+	if err != nil {
+		return fmt.Errorf("failed to set role: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisService) ListUserRoles() (map[int64]models.Role, error) {
+	values, err := s.client.HGetAll(s.ctx, KeyUserRoles).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %v", err)
+	}
+
+	roles := make(map[int64]models.Role, len(values))
+	for field, value := range values {
+		userID, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		roles[userID] = models.Role(value)
+	}
+	return roles, nil
+}
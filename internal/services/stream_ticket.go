@@ -0,0 +1,62 @@
+package services
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"sample-miniapp-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrStreamTicketInvalid = errors.New("stream ticket is invalid or expired")
+
+// Stream tickets go in the /api/events query string, so they are short-lived
+// and only the SHA-256 is stored. A ticket can be reused until it expires,
+// because EventSource reconnects to the same URL on its own.
+
+func hashStreamTicket(ticket string) string {
+	sum := sha256.Sum256([]byte(ticket))
+	return hex.EncodeToString(sum[:])
+}
+
+func (s *RedisService) CreateStreamTicket(record *models.StreamTicket) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate stream ticket: %v", err)
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(buf)
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal stream ticket: %v", err)
+	}
+
+	key := fmt.Sprintf(KeyStreamTicket, hashStreamTicket(ticket))
+	if err := s.client.Set(s.ctx, key, data, TTLStreamTicket).Err(); err != nil {
+		return "", fmt.Errorf("failed to store stream ticket: %v", err)
+	}
+
+	return ticket, nil
+}
+
+func (s *RedisService) GetStreamTicket(ticket string) (*models.StreamTicket, error) {
+	data, err := s.client.Get(s.ctx, fmt.Sprintf(KeyStreamTicket, hashStreamTicket(ticket))).Result()
+	if err == redis.Nil {
+		return nil, ErrStreamTicketInvalid
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get stream ticket: %v", err)
+	}
+
+	var record models.StreamTicket
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal stream ticket: %v", err)
+	}
+	return &record, nil
+}
@@ -0,0 +1,43 @@
+package services_test
+
+import (
+	"testing"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestStreamTicket(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	ticket, err := redisService.CreateStreamTicket(&models.StreamTicket{UserID: 999997, SessionID: "test-session"})
+	if err != nil {
+		t.Fatalf("Failed to create stream ticket: %v", err)
+	}
+
+	// EventSource reconnects with the same URL, so a ticket is reusable
+	for i := 0; i < 2; i++ {
+		record, err := redisService.GetStreamTicket(ticket)
+		if err != nil {
+			t.Fatalf("Failed to get stream ticket: %v", err)
+		}
+		if record.UserID != 999997 || record.SessionID != "test-session" {
+			t.Errorf("Stream ticket mismatch: %+v", record)
+		}
+	}
+
+	if _, err := redisService.GetStreamTicket("unknown"); err != services.ErrStreamTicketInvalid {
+		t.Errorf("Expected ErrStreamTicketInvalid, got %v", err)
+	}
+}
@@ -0,0 +1,290 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"sample-miniapp-backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	WebhookConsumerGroup = "webhooks"
+
+	webhookMaxAttempts = 8
+	webhookBaseBackoff = 5 * time.Second
+	webhookMaxBackoff  = time.Hour
+	webhookTimeout     = 10 * time.Second
+	// webhookLease is how long a claimed delivery stays hidden from other
+	// workers; it covers one send plus recording the outcome
+	webhookLease = 3 * webhookTimeout
+)
+
+// WebhookService fans events from the event stream out to subscribed HTTP
+// endpoints. Every attempt, including the first, goes through the retry
+// queue so a slow endpoint never holds up the stream consumer. Delivery is
+// at least once: a worker that dies after sending but before recording the
+// outcome leaves the delivery to be sent again, with the same
+// X-Casino-Delivery ID for receivers to deduplicate on.
+type WebhookService struct {
+	redisService *RedisService
+	httpClient   *http.Client
+}
+
+func NewWebhookService(redisService *RedisService) *WebhookService {
+	return &WebhookService{
+		redisService: redisService,
+		httpClient:   &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+func (ws *WebhookService) CreateSubscription(req *models.CreateWebhookRequest) (*models.WebhookSubscription, error) {
+	for _, t := range req.EventTypes {
+		if t != models.WebhookAllEvents && !t.IsKnown() {
+			return nil, fmt.Errorf("unknown event type: %s", t)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		buf := make([]byte, 32)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, fmt.Errorf("failed to generate webhook secret: %v", err)
+		}
+		secret = hex.EncodeToString(buf)
+	}
+
+	sub := &models.WebhookSubscription{
+		ID:         uuid.New().String(),
+		URL:        req.URL,
+		Secret:     secret,
+		EventTypes: req.EventTypes,
+		Active:     true,
+		CreatedAt:  time.Now(),
+	}
+
+	if err := ws.redisService.SaveWebhook(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// Run consumes the event stream and runs the delivery loop until ctx ends.
+func (ws *WebhookService) Run(ctx context.Context) {
+	go ws.runDeliveries(ctx)
+
+	consumer := NewEventConsumer(ws.redisService, WebhookConsumerGroup, InstanceConsumerName("dispatcher"))
+	if err := consumer.Run(ctx, ws.enqueue); err != nil && ctx.Err() == nil {
+		log.Printf("Webhook consumer stopped: %v", err)
+	}
+}
+
+// enqueue creates one delivery per matching subscription.
+func (ws *WebhookService) enqueue(event *models.Event) error {
+	subs, err := ws.redisService.ListWebhooks()
+	if err != nil {
+		return err
+	}
+
+	var payload []byte
+	for _, sub := range subs {
+		if !sub.Matches(event.Type) {
+			continue
+		}
+
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return fmt.Errorf("failed to marshal webhook payload: %v", err)
+			}
+		}
+
+		now := time.Now()
+		delivery := &models.WebhookDelivery{
+			ID:             uuid.New().String(),
+			SubscriptionID: sub.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        string(payload),
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+
+		if err := ws.redisService.SaveWebhookDelivery(delivery); err != nil {
+			return err
+		}
+		if err := ws.redisService.ScheduleWebhookDelivery(delivery.ID, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (ws *WebhookService) runDeliveries(ctx context.Context) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := ws.ProcessDueDeliveries(time.Now()); err != nil {
+				log.Printf("Webhook retry queue: %v", err)
+			}
+		}
+	}
+}
+
+// ProcessDueDeliveries attempts every delivery due by now, up to one batch.
+// Each is leased just before it is sent, so a slow batch does not hold the
+// later ones past their lease.
+func (ws *WebhookService) ProcessDueDeliveries(now time.Time) error {
+	ids, err := ws.redisService.DueWebhookDeliveries(now, 50)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		leased, err := ws.redisService.LeaseWebhookDelivery(id, now, now.Add(webhookLease))
+		if err != nil {
+			return err
+		}
+		if leased {
+			ws.attempt(id)
+		}
+	}
+	return nil
+}
+
+func (ws *WebhookService) attempt(deliveryID string) {
+	delivery, err := ws.redisService.GetWebhookDelivery(deliveryID)
+	if errors.Is(err, ErrWebhookDeliveryNotFound) {
+		log.Printf("Dropping webhook delivery %s: %v", deliveryID, err)
+		ws.redisService.CompleteWebhookDelivery(deliveryID)
+		return
+	}
+	if err != nil {
+		// Left leased; it is retried when the lease runs out
+		log.Printf("Webhook delivery %s: %v", deliveryID, err)
+		return
+	}
+
+	delivery.Attempts++
+
+	sub, err := ws.redisService.GetWebhook(delivery.SubscriptionID)
+	if err != nil {
+		// Subscription is gone; retrying cannot help
+		delivery.Attempts = webhookMaxAttempts
+		delivery.LastError = err.Error()
+	} else {
+		delivery.ResponseCode, err = ws.send(sub, delivery)
+		if err == nil {
+			delivery.Status = models.WebhookDeliveryDelivered
+			delivery.LastError = ""
+			if err := ws.redisService.SaveWebhookDelivery(delivery); err != nil {
+				log.Printf("Webhook delivery %s sent but not recorded: %v", delivery.ID, err)
+				return
+			}
+			ws.redisService.CompleteWebhookDelivery(delivery.ID)
+			return
+		}
+		delivery.LastError = err.Error()
+	}
+
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status = models.WebhookDeliveryDead
+		if err := ws.redisService.SaveWebhookDelivery(delivery); err != nil {
+			log.Printf("Webhook delivery %s failed but not recorded: %v", delivery.ID, err)
+			return
+		}
+		ws.redisService.DeadLetterWebhookDelivery(delivery.ID)
+		log.Printf("Webhook delivery %s dead-lettered after %d attempts: %s",
+			delivery.ID, delivery.Attempts, delivery.LastError)
+		return
+	}
+
+	delivery.NextAttemptAt = time.Now().Add(WebhookBackoff(delivery.Attempts))
+	ws.redisService.SaveWebhookDelivery(delivery)
+	ws.redisService.ScheduleWebhookDelivery(delivery.ID, delivery.NextAttemptAt)
+}
+
+// WebhookBackoff is the wait after the given number of failed attempts. It
+// doubles every time (5s, 10s, 20s...) up to an hour.
+func WebhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff << (attempts - 1)
+	if backoff <= 0 || backoff > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return backoff
+}
+
+func (ws *WebhookService) send(sub *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewBufferString(delivery.Payload))
+	if err != nil {
+		return 0, fmt.Errorf("failed to build request: %v", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Casino-Event", string(delivery.EventType))
+	req.Header.Set("X-Casino-Delivery", delivery.ID)
+	req.Header.Set("X-Casino-Signature", "t="+timestamp+",v1="+SignWebhookPayload(sub.Secret, timestamp, delivery.Payload))
+
+	resp, err := ws.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhookPayload is the HMAC-SHA256 of "timestamp.payload". Receivers
+// recompute it with their secret and reject stale timestamps.
+func SignWebhookPayload(secret, timestamp, payload string) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "." + payload))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ReplayDelivery re-queues a delivery for immediate sending, pulling it out
+// of the dead-letter queue if it landed there.
+func (ws *WebhookService) ReplayDelivery(id string) (*models.WebhookDelivery, error) {
+	delivery, err := ws.redisService.GetWebhookDelivery(id)
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+
+	if err := ws.redisService.SaveWebhookDelivery(delivery); err != nil {
+		return nil, err
+	}
+	ws.redisService.RemoveWebhookDeadLetter(id)
+	if err := ws.redisService.ScheduleWebhookDelivery(id, delivery.NextAttemptAt); err != nil {
+		return nil, err
+	}
+
+	return delivery, nil
+}
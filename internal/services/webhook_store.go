@@ -0,0 +1,231 @@
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"sample-miniapp-backend/internal/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+
+func (s *RedisService) SaveWebhook(sub *models.WebhookSubscription) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook: %v", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(s.ctx, fmt.Sprintf(KeyWebhook, sub.ID), data, 0)
+	pipe.SAdd(s.ctx, KeyWebhooks, sub.ID)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return fmt.Errorf("failed to save webhook: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisService) GetWebhook(id string) (*models.WebhookSubscription, error) {
+	data, err := s.client.Get(s.ctx, fmt.Sprintf(KeyWebhook, id)).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("webhook not found: %s", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook: %v", err)
+	}
+
+	var sub models.WebhookSubscription
+	if err := json.Unmarshal([]byte(data), &sub); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook: %v", err)
+	}
+	return &sub, nil
+}
+
+func (s *RedisService) ListWebhooks() ([]*models.WebhookSubscription, error) {
+	ids, err := s.client.SMembers(s.ctx, KeyWebhooks).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %v", err)
+	}
+
+	var subs []*models.WebhookSubscription
+	for _, id := range ids {
+		sub, err := s.GetWebhook(id)
+		if err != nil {
+			continue
+		}
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (s *RedisService) DeleteWebhook(id string) error {
+	pipe := s.client.TxPipeline()
+	pipe.Del(s.ctx, fmt.Sprintf(KeyWebhook, id))
+	pipe.SRem(s.ctx, KeyWebhooks, id)
+	_, err := pipe.Exec(s.ctx)
+	return err
+}
+
+func (s *RedisService) SaveWebhookDelivery(delivery *models.WebhookDelivery) error {
+	delivery.UpdatedAt = time.Now()
+
+	data, err := json.Marshal(delivery)
+	if err != nil {
+		return fmt.Errorf("failed to marshal webhook delivery: %v", err)
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.Set(s.ctx, fmt.Sprintf(KeyWebhookDelivery, delivery.ID), data, TTLWebhookDelivery)
+	pipe.ZAdd(s.ctx, KeyWebhookDeliveries, redis.Z{
+		Score:  float64(delivery.CreatedAt.Unix()),
+		Member: delivery.ID,
+	})
+	// Keep the index to the last 1000 deliveries
+	pipe.ZRemRangeByRank(s.ctx, KeyWebhookDeliveries, 0, -1001)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return fmt.Errorf("failed to save webhook delivery: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisService) GetWebhookDelivery(id string) (*models.WebhookDelivery, error) {
+	data, err := s.client.Get(s.ctx, fmt.Sprintf(KeyWebhookDelivery, id)).Result()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: %s", ErrWebhookDeliveryNotFound, id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get webhook delivery: %v", err)
+	}
+
+	var delivery models.WebhookDelivery
+	if err := json.Unmarshal([]byte(data), &delivery); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal webhook delivery: %v", err)
+	}
+	return &delivery, nil
+}
+
+// ListWebhookDeliveries returns the most recent deliveries, or only the ones
+// in the dead-letter queue when deadOnly is set.
+func (s *RedisService) ListWebhookDeliveries(deadOnly bool, limit int64) ([]*models.WebhookDelivery, error) {
+	if limit <= 0 || limit > 100 {
+		limit = 50
+	}
+
+	key := KeyWebhookDeliveries
+	if deadOnly {
+		key = KeyWebhookDeadLetter
+	}
+
+	ids, err := s.client.ZRevRange(s.ctx, key, 0, limit-1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %v", err)
+	}
+
+	var deliveries []*models.WebhookDelivery
+	for _, id := range ids {
+		delivery, err := s.GetWebhookDelivery(id)
+		if err != nil {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+func (s *RedisService) ScheduleWebhookDelivery(id string, at time.Time) error {
+	return s.client.ZAdd(s.ctx, KeyWebhookRetryQueue, redis.Z{
+		Score:  float64(at.UnixMilli()),
+		Member: id,
+	}).Err()
+}
+
+// DueWebhookDeliveries lists deliveries whose next attempt is due. They stay
+// queued; a worker must lease one with LeaseWebhookDelivery before sending.
+func (s *RedisService) DueWebhookDeliveries(now time.Time, limit int64) ([]string, error) {
+	ids, err := s.client.ZRangeByScore(s.ctx, KeyWebhookRetryQueue, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   fmt.Sprintf("%d", now.UnixMilli()),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook retry queue: %v", err)
+	}
+	return ids, nil
+}
+
+// LeaseWebhookDelivery claims a due delivery by pushing its score out to
+// until, so no other worker picks it up meanwhile. The delivery stays in the
+// queue until its outcome is recorded; if the worker dies, the lease runs out
+// and the delivery is retried. It reports false when another worker got it
+// first or it is no longer due.
+func (s *RedisService) LeaseWebhookDelivery(id string, now, until time.Time) (bool, error) {
+	for i := 0; i < 3; i++ {
+		leased, err := s.leaseWebhookDelivery(id, now, until)
+		if err == redis.TxFailedErr {
+			continue
+		}
+		if err != nil {
+			return false, fmt.Errorf("failed to lease webhook delivery: %v", err)
+		}
+		return leased, nil
+	}
+	// Lost every race; the delivery is still due next round
+	return false, nil
+}
+
+func (s *RedisService) leaseWebhookDelivery(id string, now, until time.Time) (bool, error) {
+	leased := false
+	err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		score, err := tx.ZScore(s.ctx, KeyWebhookRetryQueue, id).Result()
+		if err == redis.Nil {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if score > float64(now.UnixMilli()) {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			pipe.ZAddXX(s.ctx, KeyWebhookRetryQueue, redis.Z{
+				Score:  float64(until.UnixMilli()),
+				Member: id,
+			})
+			return nil
+		})
+		if err == nil {
+			leased = true
+		}
+		return err
+	}, KeyWebhookRetryQueue)
+	return leased, err
+}
+
+// CompleteWebhookDelivery takes a delivery off the retry queue once it has
+// been delivered or given up on.
+func (s *RedisService) CompleteWebhookDelivery(id string) error {
+	return s.client.ZRem(s.ctx, KeyWebhookRetryQueue, id).Err()
+}
+
+// DeadLetterWebhookDelivery moves a delivery from the retry queue to the
+// dead-letter queue in one step, so it is never in neither.
+func (s *RedisService) DeadLetterWebhookDelivery(id string) error {
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(s.ctx, KeyWebhookDeadLetter, redis.Z{
+		Score:  float64(time.Now().Unix()),
+		Member: id,
+	})
+	pipe.ZRem(s.ctx, KeyWebhookRetryQueue, id)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return fmt.Errorf("failed to dead-letter webhook delivery: %v", err)
+	}
+	return nil
+}
+
+func (s *RedisService) RemoveWebhookDeadLetter(id string) error {
+	return s.client.ZRem(s.ctx, KeyWebhookDeadLetter, id).Err()
+}
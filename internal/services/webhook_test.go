@@ -0,0 +1,239 @@
+package services_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/models"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestSignWebhookPayload(t *testing.T) {
+	secret := "test-secret"
+	timestamp := "1700000000"
+	payload := `{"type":"bet_placed"}`
+
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(timestamp + "." + payload))
+	expected := hex.EncodeToString(h.Sum(nil))
+
+	if got := services.SignWebhookPayload(secret, timestamp, payload); got != expected {
+		t.Errorf("Expected signature %s, got %s", expected, got)
+	}
+	if services.SignWebhookPayload("other-secret", timestamp, payload) == expected {
+		t.Error("Expected a different secret to change the signature")
+	}
+	if services.SignWebhookPayload(secret, "1700000001", payload) == expected {
+		t.Error("Expected a different timestamp to change the signature")
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		expected time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{8, 640 * time.Second},
+		{12, time.Hour},
+		{100, time.Hour},
+	}
+
+	for _, tt := range tests {
+		if got := services.WebhookBackoff(tt.attempts); got != tt.expected {
+			t.Errorf("WebhookBackoff(%d) = %v, expected %v", tt.attempts, got, tt.expected)
+		}
+	}
+}
+
+func TestWebhookDeliveryRetryAndReplay(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	var healthy atomic.Bool
+	var lastSignature atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lastSignature.Store(r.Header.Get("X-Casino-Signature"))
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	webhookService := services.NewWebhookService(redisService)
+	sub, err := webhookService.CreateSubscription(&models.CreateWebhookRequest{
+		URL:        server.URL,
+		EventTypes: []models.EventType{models.WebhookAllEvents},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create subscription: %v", err)
+	}
+	defer redisService.DeleteWebhook(sub.ID)
+
+	now := time.Now()
+	delivery := &models.WebhookDelivery{
+		ID:             fmt.Sprintf("test-delivery-%d", now.UnixNano()),
+		SubscriptionID: sub.ID,
+		EventID:        "test-event",
+		EventType:      models.EventBetPlaced,
+		Payload:        `{"type":"bet_placed"}`,
+		Status:         models.WebhookDeliveryPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+	if err := redisService.SaveWebhookDelivery(delivery); err != nil {
+		t.Fatalf("Failed to save delivery: %v", err)
+	}
+	if err := redisService.ScheduleWebhookDelivery(delivery.ID, now); err != nil {
+		t.Fatalf("Failed to schedule delivery: %v", err)
+	}
+
+	// A failed attempt is rescheduled with backoff
+	if err := webhookService.ProcessDueDeliveries(time.Now()); err != nil {
+		t.Fatalf("Failed to process deliveries: %v", err)
+	}
+	stored, err := redisService.GetWebhookDelivery(delivery.ID)
+	if err != nil {
+		t.Fatalf("Failed to get delivery: %v", err)
+	}
+	if stored.Status != models.WebhookDeliveryPending || stored.Attempts != 1 || stored.ResponseCode != 500 {
+		t.Errorf("Expected pending delivery after 1 attempt with 500, got %s after %d with %d",
+			stored.Status, stored.Attempts, stored.ResponseCode)
+	}
+	if wait := time.Until(stored.NextAttemptAt); wait < 4*time.Second || wait > services.WebhookBackoff(1) {
+		t.Errorf("Expected next attempt in about %v, got %v", services.WebhookBackoff(1), wait)
+	}
+
+	signature, _ := lastSignature.Load().(string)
+	parts := strings.Split(signature, ",")
+	if len(parts) != 2 || !strings.HasPrefix(parts[0], "t=") || !strings.HasPrefix(parts[1], "v1=") {
+		t.Fatalf("Unexpected signature header %q", signature)
+	}
+	if expected := services.SignWebhookPayload(sub.Secret, strings.TrimPrefix(parts[0], "t="), delivery.Payload); parts[1] != "v1="+expected {
+		t.Errorf("Signature header does not verify with the subscription secret")
+	}
+
+	// Keep failing until the delivery is dead-lettered
+	for i := 0; i < 20 && stored.Status == models.WebhookDeliveryPending; i++ {
+		if err := webhookService.ProcessDueDeliveries(time.Now().Add(2 * time.Hour)); err != nil {
+			t.Fatalf("Failed to process deliveries: %v", err)
+		}
+		if stored, err = redisService.GetWebhookDelivery(delivery.ID); err != nil {
+			t.Fatalf("Failed to get delivery: %v", err)
+		}
+	}
+	if stored.Status != models.WebhookDeliveryDead {
+		t.Fatalf("Expected delivery to be dead-lettered, got %s after %d attempts", stored.Status, stored.Attempts)
+	}
+	if !inDeadLetterQueue(t, redisService, delivery.ID) {
+		t.Error("Expected dead delivery in the dead-letter queue")
+	}
+
+	// Replay takes it out of the DLQ and delivers it once the endpoint recovers
+	healthy.Store(true)
+	replayed, err := webhookService.ReplayDelivery(delivery.ID)
+	if err != nil {
+		t.Fatalf("Failed to replay delivery: %v", err)
+	}
+	if replayed.Status != models.WebhookDeliveryPending || replayed.Attempts != 0 {
+		t.Errorf("Expected replayed delivery to be pending with 0 attempts, got %s with %d",
+			replayed.Status, replayed.Attempts)
+	}
+	if inDeadLetterQueue(t, redisService, delivery.ID) {
+		t.Error("Expected replayed delivery to leave the dead-letter queue")
+	}
+
+	if err := webhookService.ProcessDueDeliveries(time.Now()); err != nil {
+		t.Fatalf("Failed to process deliveries: %v", err)
+	}
+	if stored, err = redisService.GetWebhookDelivery(delivery.ID); err != nil {
+		t.Fatalf("Failed to get delivery: %v", err)
+	}
+	if stored.Status != models.WebhookDeliveryDelivered || stored.Attempts != 1 {
+		t.Errorf("Expected delivered after 1 attempt, got %s after %d", stored.Status, stored.Attempts)
+	}
+}
+
+func inDeadLetterQueue(t *testing.T, redisService *services.RedisService, id string) bool {
+	t.Helper()
+
+	dead, err := redisService.ListWebhookDeliveries(true, 100)
+	if err != nil {
+		t.Fatalf("Failed to list dead deliveries: %v", err)
+	}
+	for _, d := range dead {
+		if d.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLeaseWebhookDelivery(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	now := time.Now()
+	id := fmt.Sprintf("test-lease-%d", now.UnixNano())
+	if err := redisService.ScheduleWebhookDelivery(id, now); err != nil {
+		t.Fatalf("Failed to schedule delivery: %v", err)
+	}
+	defer redisService.CompleteWebhookDelivery(id)
+
+	leased, err := redisService.LeaseWebhookDelivery(id, now, now.Add(time.Minute))
+	if err != nil || !leased {
+		t.Fatalf("Expected the first lease to succeed, got %v (%v)", leased, err)
+	}
+	if leased, _ := redisService.LeaseWebhookDelivery(id, now, now.Add(time.Minute)); leased {
+		t.Error("Expected a leased delivery to be hidden from other workers")
+	}
+
+	// A worker that died leaves the delivery queued; it is due again once
+	// the lease runs out
+	later := now.Add(2 * time.Minute)
+	due, err := redisService.DueWebhookDeliveries(later, 1000)
+	if err != nil {
+		t.Fatalf("Failed to read due deliveries: %v", err)
+	}
+	found := false
+	for _, dueID := range due {
+		found = found || dueID == id
+	}
+	if !found {
+		t.Error("Expected the delivery to be due again after its lease expired")
+	}
+	if leased, _ := redisService.LeaseWebhookDelivery(id, later, later.Add(time.Minute)); !leased {
+		t.Error("Expected an expired lease to be taken over")
+	}
+}
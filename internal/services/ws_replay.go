@@ -0,0 +1,121 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// NextTopicSeq returns the next sequence number for a WebSocket topic.
+// Numbers are shared by every instance, so a client can resume anywhere.
+func (s *RedisService) NextTopicSeq(topic string) (int64, error) {
+	seq, err := s.client.Incr(s.ctx, fmt.Sprintf(KeyWSTopicSeq, topic)).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to sequence topic %s: %v", topic, err)
+	}
+	return seq, nil
+}
+
+// BufferTopicMessage keeps a sequenced message in the topic's ring buffer of
+// the last WSReplayBufferSize messages.
+func (s *RedisService) BufferTopicMessage(topic string, seq int64, payload []byte) error {
+	bufferKey := fmt.Sprintf(KeyWSTopicBuffer, topic)
+
+	pipe := s.client.TxPipeline()
+	pipe.ZAdd(s.ctx, bufferKey, redis.Z{Score: float64(seq), Member: payload})
+	pipe.ZRemRangeByRank(s.ctx, bufferKey, 0, -WSReplayBufferSize-1)
+	pipe.Expire(s.ctx, bufferKey, TTLWSReplay)
+	pipe.Expire(s.ctx, fmt.Sprintf(KeyWSTopicSeq, topic), TTLWSReplay)
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return fmt.Errorf("failed to buffer topic message: %v", err)
+	}
+	return nil
+}
+
+// ReadTopicMessages returns the buffered messages after afterSeq, oldest
+// first, and the topic's current sequence. complete is false when some of
+// the missed messages have already left the buffer.
+func (s *RedisService) ReadTopicMessages(topic string, afterSeq int64) (messages [][]byte, current int64, complete bool, err error) {
+	current, err = s.client.Get(s.ctx, fmt.Sprintf(KeyWSTopicSeq, topic)).Int64()
+	if err == redis.Nil {
+		// Nothing sequenced yet, or idle long enough to expire
+		return nil, 0, afterSeq == 0, nil
+	}
+	if err != nil {
+		return nil, 0, false, fmt.Errorf("failed to read topic seq: %v", err)
+	}
+	if afterSeq >= current {
+		return nil, current, afterSeq == current, nil
+	}
+
+	entries, err := s.client.ZRangeByScoreWithScores(s.ctx, fmt.Sprintf(KeyWSTopicBuffer, topic), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(afterSeq, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, current, false, fmt.Errorf("failed to read topic buffer: %v", err)
+	}
+
+	complete = len(entries) > 0 && int64(entries[0].Score) == afterSeq+1
+	for _, entry := range entries {
+		messages = append(messages, []byte(entry.Member.(string)))
+	}
+	return messages, current, complete, nil
+}
+
+// NextTopicSeqs reserves a sequence number for each entry of topics in one
+// round trip. A topic listed twice gets consecutive numbers.
+func (s *RedisService) NextTopicSeqs(topics []string) ([]int64, error) {
+	pipe := s.client.Pipeline()
+	cmds := make([]*redis.IntCmd, len(topics))
+	for i, topic := range topics {
+		cmds[i] = pipe.Incr(s.ctx, fmt.Sprintf(KeyWSTopicSeq, topic))
+	}
+	if _, err := pipe.Exec(s.ctx); err != nil {
+		return nil, fmt.Errorf("failed to sequence topics: %v", err)
+	}
+
+	seqs := make([]int64, len(topics))
+	for i, cmd := range cmds {
+		seqs[i] = cmd.Val()
+	}
+	return seqs, nil
+}
+
+// TopicMessage is a sequenced message to keep for replay.
+type TopicMessage struct {
+	Topic   string
+	Seq     int64
+	Payload []byte
+}
+
+// BufferAndPublish keeps messages for replay and then publishes payloads to
+// the backplane in order, all in one round trip. It reports, per payload,
+// whether it was published; the caller delivers the rest some other way.
+func (s *RedisService) BufferAndPublish(messages []TopicMessage, payloads [][]byte) ([]bool, error) {
+	pipe := s.client.Pipeline()
+	for _, message := range messages {
+		bufferKey := fmt.Sprintf(KeyWSTopicBuffer, message.Topic)
+		pipe.ZAdd(s.ctx, bufferKey, redis.Z{Score: float64(message.Seq), Member: message.Payload})
+		pipe.ZRemRangeByRank(s.ctx, bufferKey, 0, -WSReplayBufferSize-1)
+		pipe.Expire(s.ctx, bufferKey, TTLWSReplay)
+		pipe.Expire(s.ctx, fmt.Sprintf(KeyWSTopicSeq, message.Topic), TTLWSReplay)
+	}
+
+	publishes := make([]*redis.IntCmd, len(payloads))
+	for i, payload := range payloads {
+		publishes[i] = pipe.Publish(s.ctx, KeyWSBackplane, payload)
+	}
+
+	_, err := pipe.Exec(s.ctx)
+
+	published := make([]bool, len(payloads))
+	for i, cmd := range publishes {
+		published[i] = cmd.Err() == nil
+	}
+	if err != nil {
+		return published, fmt.Errorf("failed to publish to backplane: %v", err)
+	}
+	return published, nil
+}
@@ -0,0 +1,102 @@
+package services_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"sample-miniapp-backend/internal/config"
+	"sample-miniapp-backend/internal/services"
+)
+
+func TestTopicReplayBuffer(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	topic := fmt.Sprintf("game:test-replay-%d", time.Now().UnixNano())
+	total := services.WSReplayBufferSize + 10
+
+	for i := 0; i < total; i++ {
+		seq, err := redisService.NextTopicSeq(topic)
+		if err != nil {
+			t.Fatalf("Failed to sequence: %v", err)
+		}
+		if err := redisService.BufferTopicMessage(topic, seq, []byte(fmt.Sprintf(`{"seq":%d}`, seq))); err != nil {
+			t.Fatalf("Failed to buffer: %v", err)
+		}
+	}
+
+	messages, current, complete, err := redisService.ReadTopicMessages(topic, int64(total-3))
+	if err != nil {
+		t.Fatalf("Failed to read buffer: %v", err)
+	}
+	if current != int64(total) || !complete || len(messages) != 3 {
+		t.Errorf("Expected 3 replayable messages up to %d, got %d (current %d, complete %v)",
+			total, len(messages), current, complete)
+	}
+
+	// The first messages have been trimmed, so a client this far behind
+	// needs a snapshot
+	_, _, complete, err = redisService.ReadTopicMessages(topic, 1)
+	if err != nil {
+		t.Fatalf("Failed to read buffer: %v", err)
+	}
+	if complete {
+		t.Error("Expected gap wider than the buffer to be incomplete")
+	}
+}
+
+func TestTopicBatchSequencing(t *testing.T) {
+	cfg := &config.Config{
+		RedisURL:  "localhost:6379",
+		RedisPass: "",
+		RedisDB:   0,
+	}
+
+	redisService, err := services.NewRedisService(cfg)
+	if err != nil {
+		t.Skipf("Redis not available: %v", err)
+	}
+	defer redisService.Close()
+
+	topicA := fmt.Sprintf("game:test-batch-a-%d", time.Now().UnixNano())
+	topicB := fmt.Sprintf("game:test-batch-b-%d", time.Now().UnixNano())
+
+	seqs, err := redisService.NextTopicSeqs([]string{topicA, topicB, topicA})
+	if err != nil {
+		t.Fatalf("Failed to sequence batch: %v", err)
+	}
+	if len(seqs) != 3 || seqs[0] != 1 || seqs[1] != 1 || seqs[2] != 2 {
+		t.Fatalf("Expected seqs [1 1 2], got %v", seqs)
+	}
+
+	published, err := redisService.BufferAndPublish([]services.TopicMessage{
+		{Topic: topicA, Seq: 1, Payload: []byte(`{"seq":1}`)},
+		{Topic: topicB, Seq: 1, Payload: []byte(`{"seq":1}`)},
+		{Topic: topicA, Seq: 2, Payload: []byte(`{"seq":2}`)},
+	}, [][]byte{[]byte(`{}`), []byte(`{}`)})
+	if err != nil {
+		t.Fatalf("Failed to buffer and publish: %v", err)
+	}
+	if len(published) != 2 || !published[0] || !published[1] {
+		t.Errorf("Expected both payloads published, got %v", published)
+	}
+
+	messages, current, complete, err := redisService.ReadTopicMessages(topicA, 0)
+	if err != nil {
+		t.Fatalf("Failed to read buffer: %v", err)
+	}
+	if current != 2 || !complete || len(messages) != 2 || string(messages[1]) != `{"seq":2}` {
+		t.Errorf("Expected both topic A messages in order, got %q (current %d, complete %v)",
+			messages, current, complete)
+	}
+}
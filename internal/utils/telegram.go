@@ -1,9 +1,13 @@
 package utils
 
 import (
+	"bytes"
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"sort"
@@ -24,7 +28,119 @@ func ValidateTelegramInitData(botToken string, initData string) (bool, error) {
 	}
 
 	parsed.Del("hash")
+	dataCheckString := buildDataCheckString(parsed)
 
+	secretKey := hmac.New(sha256.New, []byte("WebAppData"))
+	secretKey.Write([]byte(botToken))
+
+	h := hmac.New(sha256.New, secretKey.Sum(nil))
+	h.Write([]byte(dataCheckString))
+	calculatedHash := hex.EncodeToString(h.Sum(nil))
+
+	return hmac.Equal([]byte(calculatedHash), []byte(hash)), nil
+}
+
+// ValidateTelegramLoginWidget checks a Login Widget payload, in the same
+// query string form as initData. Unlike initData, the HMAC key is the
+// SHA-256 of the bot token.
+func ValidateTelegramLoginWidget(botToken string, payload string) (bool, error) {
+	parsed, err := url.ParseQuery(payload)
+	if err != nil {
+		return false, err
+	}
+
+	hash := parsed.Get("hash")
+	if hash == "" {
+		return false, fmt.Errorf("hash not found in login payload")
+	}
+
+	parsed.Del("hash")
+	dataCheckString := buildDataCheckString(parsed)
+
+	secretKey := sha256.Sum256([]byte(botToken))
+
+	h := hmac.New(sha256.New, secretKey[:])
+	h.Write([]byte(dataCheckString))
+	calculatedHash := hex.EncodeToString(h.Sum(nil))
+
+	return hmac.Equal([]byte(calculatedHash), []byte(hash)), nil
+}
+
+// LoginWidgetPayload turns the user object the widget hands to its
+// JavaScript callback into the query string form. Numbers keep their exact
+// text, since the hash covers it.
+func LoginWidgetPayload(body []byte) (string, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+
+	var fields map[string]interface{}
+	if err := decoder.Decode(&fields); err != nil {
+		return "", fmt.Errorf("invalid login payload: %v", err)
+	}
+
+	values := url.Values{}
+	for k, v := range fields {
+		switch v := v.(type) {
+		case string:
+			values.Set(k, v)
+		case json.Number:
+			values.Set(k, v.String())
+		case bool:
+			values.Set(k, strconv.FormatBool(v))
+		default:
+			return "", fmt.Errorf("invalid login payload: unexpected value for %s", k)
+		}
+	}
+	return values.Encode(), nil
+}
+
+// ValidateTelegramSignature checks the Ed25519 signature field, which
+// Telegram adds so initData can be verified without the bot's token. botID
+// is the bot the Mini App was opened through.
+func ValidateTelegramSignature(publicKey ed25519.PublicKey, botID int64, initData string) (bool, error) {
+	parsed, err := url.ParseQuery(initData)
+	if err != nil {
+		return false, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parsed.Get("signature"), "="))
+	if err != nil || len(signature) == 0 {
+		return false, fmt.Errorf("signature not found in initData")
+	}
+
+	return ed25519.Verify(publicKey, []byte(signedDataCheckString(botID, parsed)), signature), nil
+}
+
+// SignedInitDataDigest is the SHA-256 of what ValidateTelegramSignature
+// verifies. The signature does not cover hash, and its own encoding can be
+// padded, so this digest is what identifies one signed initData for replay
+// protection.
+func SignedInitDataDigest(botID int64, initData string) (string, error) {
+	parsed, err := url.ParseQuery(initData)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256([]byte(signedDataCheckString(botID, parsed)))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func signedDataCheckString(botID int64, parsed url.Values) string {
+	parsed.Del("hash")
+	parsed.Del("signature")
+	return fmt.Sprintf("%d:WebAppData\n%s", botID, buildDataCheckString(parsed))
+}
+
+// BotIDFromToken returns the numeric bot ID at the start of a bot token.
+func BotIDFromToken(botToken string) (int64, error) {
+	id, _, found := strings.Cut(botToken, ":")
+	if !found {
+		return 0, fmt.Errorf("malformed bot token")
+	}
+	return strconv.ParseInt(id, 10, 64)
+}
+
+func buildDataCheckString(parsed url.Values) string {
 	keys := make([]string, 0, len(parsed))
 	for k := range parsed {
 		keys = append(keys, k)
@@ -36,36 +152,34 @@ func ValidateTelegramInitData(botToken string, initData string) (bool, error) {
 		v := parsed.Get(k)
 		dataCheckArr = append(dataCheckArr, fmt.Sprintf("%s=%s", k, v))
 	}
-	dataCheckString := strings.Join(dataCheckArr, "\n")
-
-	secretKey := hmac.New(sha256.New, []byte("WebAppData"))
-	secretKey.Write([]byte(botToken))
-
-	h := hmac.New(sha256.New, secretKey.Sum(nil))
-	h.Write([]byte(dataCheckString))
-	calculatedHash := hex.EncodeToString(h.Sum(nil))
-
-	return calculatedHash == hash, nil
+	return strings.Join(dataCheckArr, "\n")
 }
 
-func CheckInitDataAge(initData string) (bool, error) {
+// InitDataAuthDate returns when Telegram issued the initData.
+func InitDataAuthDate(initData string) (time.Time, error) {
 	parsed, err := url.ParseQuery(initData)
 	if err != nil {
-		return false, err
+		return time.Time{}, err
 	}
 
 	authDateStr := parsed.Get("auth_date")
 	if authDateStr == "" {
-		return false, fmt.Errorf("auth_date not found")
+		return time.Time{}, fmt.Errorf("auth_date not found")
 	}
 
 	authDate, err := strconv.ParseInt(authDateStr, 10, 64)
 	if err != nil {
-		return false, err
+		return time.Time{}, err
 	}
 
-	now := time.Now().Unix()
-	maxAge := int64(24 * 60 * 60)
+	return time.Unix(authDate, 0), nil
+}
+
+func CheckInitDataAge(initData string, maxAge time.Duration) (bool, error) {
+	authDate, err := InitDataAuthDate(initData)
+	if err != nil {
+		return false, err
+	}
 
-	return (now - authDate) < maxAge, nil
+	return time.Since(authDate) < maxAge, nil
 }
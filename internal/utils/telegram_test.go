@@ -0,0 +1,117 @@
+package utils_test
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"sample-miniapp-backend/internal/utils"
+)
+
+func TestValidateTelegramSignature(t *testing.T) {
+	publicKey, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+
+	authDate := time.Now().Unix()
+	user := `{"id":42,"first_name":"Test"}`
+	dataCheckString := fmt.Sprintf("12345:WebAppData\nauth_date=%d\nuser=%s", authDate, user)
+	signature := ed25519.Sign(privateKey, []byte(dataCheckString))
+
+	values := url.Values{}
+	values.Set("auth_date", fmt.Sprint(authDate))
+	values.Set("user", user)
+	values.Set("hash", "ignored")
+	values.Set("signature", base64.RawURLEncoding.EncodeToString(signature))
+	initData := values.Encode()
+
+	valid, err := utils.ValidateTelegramSignature(publicKey, 12345, initData)
+	if err != nil || !valid {
+		t.Errorf("Expected valid signature, got %v, %v", valid, err)
+	}
+
+	valid, _ = utils.ValidateTelegramSignature(publicKey, 54321, initData)
+	if valid {
+		t.Error("Signature accepted for the wrong bot")
+	}
+
+	// Changing hash or padding the signature keeps it valid, so neither may
+	// change the replay key
+	digest, err := utils.SignedInitDataDigest(12345, initData)
+	if err != nil {
+		t.Fatalf("Failed to digest initData: %v", err)
+	}
+	altered := url.Values{}
+	for k, v := range values {
+		altered[k] = v
+	}
+	altered.Set("hash", "changed")
+	altered.Set("signature", values.Get("signature")+"=")
+	if valid, _ := utils.ValidateTelegramSignature(publicKey, 12345, altered.Encode()); !valid {
+		t.Fatal("Expected altered hash and padding to keep the signature valid")
+	}
+	if other, _ := utils.SignedInitDataDigest(12345, altered.Encode()); other != digest {
+		t.Error("Replay key changed with hash or signature encoding")
+	}
+
+	fresh, err := utils.CheckInitDataAge(initData, time.Minute)
+	if err != nil || !fresh {
+		t.Errorf("Expected fresh initData, got %v, %v", fresh, err)
+	}
+	values.Set("auth_date", fmt.Sprint(authDate-120))
+	fresh, _ = utils.CheckInitDataAge(values.Encode(), time.Minute)
+	if fresh {
+		t.Error("Expected initData older than max age to be rejected")
+	}
+}
+
+func TestValidateTelegramLoginWidget(t *testing.T) {
+	botToken := "12345:test-token"
+	authDate := time.Now().Unix()
+
+	dataCheckString := fmt.Sprintf("auth_date=%d\nfirst_name=Test\nid=42\nusername=tester", authDate)
+	secretKey := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secretKey[:])
+	mac.Write([]byte(dataCheckString))
+	hash := hex.EncodeToString(mac.Sum(nil))
+
+	body := fmt.Sprintf(`{"id":42,"first_name":"Test","username":"tester","auth_date":%d,"hash":"%s"}`, authDate, hash)
+	payload, err := utils.LoginWidgetPayload([]byte(body))
+	if err != nil {
+		t.Fatalf("Failed to read payload: %v", err)
+	}
+
+	valid, err := utils.ValidateTelegramLoginWidget(botToken, payload)
+	if err != nil || !valid {
+		t.Errorf("Expected valid login, got %v, %v", valid, err)
+	}
+
+	valid, _ = utils.ValidateTelegramLoginWidget("12345:other-token", payload)
+	if valid {
+		t.Error("Login accepted with the wrong bot token")
+	}
+
+	// initData's WebAppData key must not validate a widget payload
+	valid, _ = utils.ValidateTelegramInitData(botToken, payload)
+	if valid {
+		t.Error("Login payload accepted as initData")
+	}
+
+	tampered, _ := utils.LoginWidgetPayload([]byte(fmt.Sprintf(`{"id":43,"first_name":"Test","username":"tester","auth_date":%d,"hash":"%s"}`, authDate, hash)))
+	valid, _ = utils.ValidateTelegramLoginWidget(botToken, tampered)
+	if valid {
+		t.Error("Tampered login accepted")
+	}
+
+	if fresh, err := utils.CheckInitDataAge(payload, time.Hour); err != nil || !fresh {
+		t.Errorf("Expected a fresh login, got %v, %v", fresh, err)
+	}
+}